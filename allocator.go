@@ -0,0 +1,45 @@
+package queue
+
+// Allocator provides the backing buffers of a queue, so that they can be pooled or taken
+// from an arena instead of being allocated with make and left to the garbage collector.
+// Its dynamic type must be comparable, as a pointer is, since SwapWith compares the
+// Allocators of two queues with ==, which panics for a struct holding a map or a slice.
+type Allocator interface {
+	// Alloc returns a slice of length n with every element nil. n is a power of two, unless
+	// the queue has grown past the threshold set with SetGrowthThreshold.
+	Alloc(n int) []interface{}
+	// Free is given a buffer the queue no longer uses. It may still hold references to
+	// elements, so it should be cleared before it is handed out again.
+	Free(buf []interface{})
+}
+
+// NewWithAllocator constructs and returns a new Queue whose backing buffers come from a
+// and are returned to it when the queue resizes. A nil Allocator gives the same queue as
+// New. Copies of the queue made by Clone, Split and the like share the Allocator.
+func NewWithAllocator(a Allocator) *Queue {
+	q := New()
+	if a != nil {
+		q.allocator = a
+		q.buf = q.allocBuf(minQueueLen)
+	}
+	return q
+}
+
+// allocBuf returns a new backing buffer of length n.
+func (q *Queue) allocBuf(n int) []interface{} {
+	if q.allocator == nil {
+		return make([]interface{}, n)
+	}
+	buf := q.allocator.Alloc(n)
+	if len(buf) != n {
+		panic("allocator returned a buffer of the wrong length")
+	}
+	return buf
+}
+
+// freeBuf hands a backing buffer that is no longer used back to the allocator.
+func (q *Queue) freeBuf(buf []interface{}) {
+	if q.allocator != nil {
+		q.allocator.Free(buf)
+	}
+}
@@ -0,0 +1,86 @@
+package queue
+
+import "testing"
+
+// poolAllocator keeps freed buffers for reuse, keyed by length.
+type poolAllocator struct {
+	free          map[int][][]interface{}
+	allocs, frees int
+}
+
+func (p *poolAllocator) Alloc(n int) []interface{} {
+	p.allocs++
+	if bufs := p.free[n]; len(bufs) > 0 {
+		p.free[n] = bufs[:len(bufs)-1]
+		return bufs[len(bufs)-1]
+	}
+	return make([]interface{}, n)
+}
+
+func (p *poolAllocator) Free(buf []interface{}) {
+	p.frees++
+	for i := range buf {
+		buf[i] = nil
+	}
+	p.free[len(buf)] = append(p.free[len(buf)], buf)
+}
+
+func TestNewWithAllocator(t *testing.T) {
+	p := &poolAllocator{free: make(map[int][][]interface{})}
+	q := NewWithAllocator(p)
+
+	for round := 0; round < 3; round++ {
+		for i := 0; i < 1000; i++ {
+			q.Add(i)
+		}
+		for i := 0; i < 1000; i++ {
+			if q.Peek().(int) != i {
+				t.Fatalf("round %d: head is %v, want %d", round, q.Peek(), i)
+			}
+			q.Remove()
+		}
+	}
+	if p.allocs != p.frees+1 {
+		t.Errorf("%d allocations for %d frees", p.allocs, p.frees)
+	}
+	if p.allocs != 1+q.ResizeCount() || q.ResizeCount() == 0 {
+		t.Errorf("%d allocations for %d resizes", p.allocs, q.ResizeCount())
+	}
+	pooled := 0
+	for _, bufs := range p.free {
+		pooled += len(bufs)
+	}
+	if pooled != 6 {
+		t.Error("pool holds", pooled, "buffers")
+	}
+
+	q = NewWithAllocator(nil)
+	q.Add(1)
+	if q.Length() != 1 {
+		t.Error("queue without an allocator has length", q.Length())
+	}
+}
+
+func TestQueueSwapWithAllocators(t *testing.T) {
+	p := &poolAllocator{free: make(map[int][][]interface{})}
+	q, other := NewWithAllocator(p), NewWithAllocator(p)
+	q.Add(1)
+	q.SwapWith(other)
+	if q.Length() != 0 || other.Length() != 1 {
+		t.Error("swapping queues sharing an allocator left lengths", q.Length(), other.Length())
+	}
+
+	for _, b := range []*Queue{New(), NewWithAllocator(&poolAllocator{free: make(map[int][][]interface{})})} {
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Error("swapping queues with different allocators should panic")
+				}
+			}()
+			q.SwapWith(b)
+		}()
+		if q.Length() != 0 || b.Length() != 0 {
+			t.Error("failed swap changed the queues")
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package queue
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// BlockingQueue is a bounded queue that can be shared between goroutines, in which Push
+// blocks while the queue is full and Pop blocks while it is empty. Unlike a channel, a
+// blocked call can be abandoned by cancelling its context.
+type BlockingQueue struct {
+	mu                sync.Mutex
+	notEmpty, notFull sync.Cond
+	q                 *Queue
+	max               int
+}
+
+// NewBlocking constructs and returns a new BlockingQueue that holds at most capacity
+// elements.
+func NewBlocking(capacity int) *BlockingQueue {
+	if capacity < 1 {
+		panic("blocking queue capacity must be at least 1")
+	}
+	b := &BlockingQueue{q: New(), max: capacity}
+	b.notEmpty.L = &b.mu
+	b.notFull.L = &b.mu
+	return b
+}
+
+// Length returns the number of elements currently stored in the queue.
+func (b *BlockingQueue) Length() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.q.Length()
+}
+
+// Push puts an element on the end of the queue, waiting for room if the queue is full. If
+// ctx is done before there is room, the element is not added and ctx.Err() is returned.
+func (b *BlockingQueue) Push(ctx context.Context, elem interface{}) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for b.q.Length() >= b.max {
+		if err := b.wait(ctx, &b.notFull); err != nil {
+			return err
+		}
+	}
+	b.q.Add(elem)
+	b.notEmpty.Broadcast()
+	return nil
+}
+
+// Pop removes and returns the element at the head of the queue, waiting for one if the
+// queue is empty. If ctx is done before an element arrives, Pop returns ctx.Err().
+func (b *BlockingQueue) Pop(ctx context.Context) (interface{}, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for b.q.Length() == 0 {
+		if err := b.wait(ctx, &b.notEmpty); err != nil {
+			return nil, err
+		}
+	}
+	elem := b.q.Pop()
+	b.notFull.Broadcast()
+	return elem, nil
+}
+
+// Poll removes and returns the element at the head of the queue and true, waiting up to
+// timeout for one if the queue is empty, or returns nil and false if none arrives in time. A
+// timeout of zero or less does not wait at all.
+func (b *BlockingQueue) Poll(timeout time.Duration) (interface{}, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	elem, err := b.Pop(ctx)
+	return elem, err == nil
+}
+
+// wait blocks on c until it is signalled or ctx is done, returning ctx.Err() in the latter
+// case. b.mu must be held.
+func (b *BlockingQueue) wait(ctx context.Context, c *sync.Cond) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if ctx.Done() == nil {
+		c.Wait()
+		return nil
+	}
+
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			b.mu.Lock()
+			c.Broadcast()
+			b.mu.Unlock()
+		case <-stop:
+		}
+	}()
+	c.Wait()
+	close(stop)
+	return ctx.Err()
+}
@@ -0,0 +1,141 @@
+package queue
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBlockingQueue(t *testing.T) {
+	ctx := context.Background()
+	b := NewBlocking(2)
+
+	if err := b.Push(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Push(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	if b.Length() != 2 {
+		t.Error("queue has length", b.Length())
+	}
+	for _, want := range []int{1, 2} {
+		if v, err := b.Pop(ctx); err != nil || v.(int) != want {
+			t.Errorf("pop returned %v, %v", v, err)
+		}
+	}
+}
+
+func TestBlockingQueueBlocks(t *testing.T) {
+	ctx := context.Background()
+	b := NewBlocking(1)
+
+	done := make(chan interface{})
+	go func() {
+		v, _ := b.Pop(ctx)
+		done <- v
+	}()
+	select {
+	case v := <-done:
+		t.Fatalf("pop on empty queue returned %v", v)
+	case <-time.After(10 * time.Millisecond):
+	}
+	b.Push(ctx, 1)
+	if v := <-done; v.(int) != 1 {
+		t.Errorf("blocked pop returned %v", v)
+	}
+
+	b.Push(ctx, 2)
+	go func() {
+		b.Push(ctx, 3)
+		done <- nil
+	}()
+	select {
+	case <-done:
+		t.Fatal("push on full queue returned")
+	case <-time.After(10 * time.Millisecond):
+	}
+	b.Pop(ctx)
+	<-done
+	if v, _ := b.Pop(ctx); v.(int) != 3 {
+		t.Errorf("blocked push added %v", v)
+	}
+}
+
+func TestBlockingQueueCancel(t *testing.T) {
+	b := NewBlocking(1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if v, err := b.Pop(ctx); err != context.DeadlineExceeded {
+		t.Errorf("pop on empty queue returned %v, %v", v, err)
+	}
+
+	b.Push(context.Background(), 1)
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if err := b.Push(ctx, 2); err != context.Canceled {
+		t.Errorf("push with cancelled context returned %v", err)
+	}
+	if b.Length() != 1 {
+		t.Error("cancelled push changed queue length to", b.Length())
+	}
+}
+
+func TestBlockingQueuePoll(t *testing.T) {
+	b := NewBlocking(2)
+
+	if v, ok := b.Poll(0); ok || v != nil {
+		t.Errorf("poll on empty queue returned %v, %v", v, ok)
+	}
+	start := time.Now()
+	if _, ok := b.Poll(20 * time.Millisecond); ok {
+		t.Error("poll on empty queue succeeded")
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Error("poll gave up after", elapsed)
+	}
+
+	b.Push(context.Background(), 1)
+	if v, ok := b.Poll(0); !ok || v.(int) != 1 {
+		t.Errorf("poll returned %v, %v", v, ok)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		b.Push(context.Background(), 2)
+	}()
+	if v, ok := b.Poll(time.Minute); !ok || v.(int) != 2 {
+		t.Errorf("waiting poll returned %v, %v", v, ok)
+	}
+}
+
+func TestBlockingQueueConcurrent(t *testing.T) {
+	const producers, perProducer = 4, 1000
+	ctx := context.Background()
+	b := NewBlocking(8)
+
+	var wg sync.WaitGroup
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perProducer; i++ {
+				b.Push(ctx, i)
+			}
+		}()
+	}
+	sum := 0
+	for i := 0; i < producers*perProducer; i++ {
+		v, err := b.Pop(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum += v.(int)
+	}
+	wg.Wait()
+	if want := producers * perProducer * (perProducer - 1) / 2; sum != want {
+		t.Errorf("popped elements sum to %d, expected %d", sum, want)
+	}
+}
@@ -0,0 +1,105 @@
+package queue
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// ByteQueue is a queue of bytes, using the same ring buffer design as Queue but storing the
+// bytes directly rather than boxed in interface{} values. It implements io.Writer, adding
+// bytes to the end of the queue, and io.Reader, removing them from the front. Unlike
+// bytes.Buffer, reading never needs to shift the remaining data to reclaim space.
+type ByteQueue struct {
+	buf               []byte
+	head, tail, count int
+}
+
+// NewByteQueue constructs and returns a new ByteQueue.
+func NewByteQueue() *ByteQueue {
+	return &ByteQueue{buf: make([]byte, minQueueLen)}
+}
+
+// Len returns the number of bytes currently stored in the queue.
+func (b *ByteQueue) Len() int {
+	return b.count
+}
+
+// peek copies up to len(p) bytes from the front of the queue into p without removing them,
+// and returns the number of bytes copied.
+func (b *ByteQueue) peek(p []byte) int {
+	if len(p) > b.count {
+		p = p[:b.count]
+	}
+	if b.head+b.count <= len(b.buf) {
+		return copy(p, b.buf[b.head:b.head+b.count])
+	}
+	n := copy(p, b.buf[b.head:])
+	return n + copy(p[n:], b.buf[:b.tail])
+}
+
+func (b *ByteQueue) resize(n int) {
+	newBuf := make([]byte, roundCapacity(n, minQueueLen))
+
+	b.peek(newBuf)
+
+	b.head = 0
+	b.tail = b.count & (len(newBuf) - 1)
+	b.buf = newBuf
+}
+
+// Write implements io.Writer, putting the bytes of p on the end of the queue and growing it
+// as needed. It always returns len(p) and a nil error.
+func (b *ByteQueue) Write(p []byte) (int, error) {
+	if b.count+len(p) > len(b.buf) {
+		b.resize(b.count + len(p))
+	}
+
+	n := copy(b.buf[b.tail:], p)
+	copy(b.buf, p[n:])
+	b.tail = (b.tail + len(p)) & (len(b.buf) - 1)
+	b.count += len(p)
+	return len(p), nil
+}
+
+// Read implements io.Reader, removing up to len(p) bytes from the front of the queue and
+// copying them into p. If the queue is empty, Read returns io.EOF, unless len(p) is zero.
+func (b *ByteQueue) Read(p []byte) (int, error) {
+	if b.count == 0 {
+		if len(p) == 0 {
+			return 0, nil
+		}
+		return 0, io.EOF
+	}
+
+	n := b.peek(p)
+	b.head = (b.head + n) & (len(b.buf) - 1)
+	b.count -= n
+	if len(b.buf) > minQueueLen && b.count*4 <= len(b.buf) {
+		b.resize(b.count * 2)
+	}
+	return n, nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler, encoding the queue as its length, as a
+// uvarint, followed by its bytes in order.
+func (b *ByteQueue) MarshalBinary() ([]byte, error) {
+	data := make([]byte, binary.MaxVarintLen64+b.count)
+	n := binary.PutUvarint(data, uint64(b.count))
+	n += b.peek(data[n:])
+	return data[:n], nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler, replacing the contents of the queue
+// with bytes encoded by MarshalBinary.
+func (b *ByteQueue) UnmarshalBinary(data []byte) error {
+	length, n := binary.Uvarint(data)
+	if n <= 0 || length != uint64(len(data)-n) {
+		return errors.New("queue: invalid ByteQueue encoding")
+	}
+	b.buf = make([]byte, roundCapacity(len(data)-n, minQueueLen))
+	b.head = 0
+	b.count = copy(b.buf, data[n:])
+	b.tail = b.count & (len(b.buf) - 1)
+	return nil
+}
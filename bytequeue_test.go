@@ -0,0 +1,81 @@
+package queue
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestByteQueue(t *testing.T) {
+	b := NewByteQueue()
+
+	if n, err := b.Read(make([]byte, 4)); n != 0 || err != io.EOF {
+		t.Errorf("read from empty queue returned %d, %v", n, err)
+	}
+	if n, err := b.Read(nil); n != 0 || err != nil {
+		t.Errorf("empty read from empty queue returned %d, %v", n, err)
+	}
+
+	// interleave writes and reads so that the data wraps around the buffer
+	var want, got bytes.Buffer
+	p := make([]byte, 7)
+	for i := 0; i < 200; i++ {
+		chunk := bytes.Repeat([]byte{byte(i)}, i%13)
+		if n, err := b.Write(chunk); n != len(chunk) || err != nil {
+			t.Fatalf("write returned %d, %v", n, err)
+		}
+		want.Write(chunk)
+		if i%3 == 0 {
+			n, _ := b.Read(p)
+			got.Write(p[:n])
+		}
+		if b.Len() != want.Len()-got.Len() {
+			t.Fatalf("queue has length %d, expected %d", b.Len(), want.Len()-got.Len())
+		}
+	}
+	if _, err := io.Copy(&got, b); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Bytes(), want.Bytes()) {
+		t.Error("bytes read don't match bytes written")
+	}
+	if b.Len() != 0 || len(b.buf) != minQueueLen {
+		t.Errorf("drained queue has length %d and capacity %d", b.Len(), len(b.buf))
+	}
+}
+
+func TestByteQueueMarshalBinary(t *testing.T) {
+	b := NewByteQueue()
+	b.Write(bytes.Repeat([]byte("abc"), 5))
+	b.Read(make([]byte, 10))
+	b.Write([]byte("defghijklmn"))
+
+	data, err := b.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var d ByteQueue
+	if err := d.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := io.ReadAll(&d)
+	if string(got) != "bcabcdefghijklmn" {
+		t.Errorf("decoded %q", got)
+	}
+
+	for _, bad := range [][]byte{nil, {5, 'a'}, {1, 'a', 'b'}} {
+		if err := d.UnmarshalBinary(bad); err == nil {
+			t.Errorf("decoding %v succeeded", bad)
+		}
+	}
+}
+
+func BenchmarkByteQueueTickTock(b *testing.B) {
+	q := NewByteQueue()
+	p := make([]byte, 512)
+	b.SetBytes(int64(len(p)))
+	for i := 0; i < b.N; i++ {
+		q.Write(p)
+		q.Read(p)
+	}
+}
@@ -0,0 +1,33 @@
+package queue
+
+import "errors"
+
+// ErrStaleCheckpoint is returned by Restore when the queue has changed in a way the
+// checkpoint can't be rolled back from.
+var ErrStaleCheckpoint = errors.New("queue: checkpoint is stale")
+
+// Checkpoint records the state of a queue at the time of a call to Snapshot.
+type Checkpoint struct {
+	head, count int
+	generation  int
+	popped      uint64
+}
+
+// Snapshot returns a Checkpoint that Restore can later roll the queue back to. Unlike Clone,
+// it copies nothing.
+func (q *Queue) Snapshot() Checkpoint {
+	return Checkpoint{head: q.head, count: q.count, generation: q.resizes, popped: q.popped}
+}
+
+// Restore rolls the queue back to the state recorded by c, removing the elements added
+// since. It is meant for append-only use: it returns ErrStaleCheckpoint and leaves the queue
+// untouched if elements were removed from the front since the snapshot, or if the backing
+// buffer was reallocated in between. Other changes, such as Set or Insert, are not detected.
+func (q *Queue) Restore(c Checkpoint) error {
+	if c.generation != q.resizes || c.popped != q.popped || c.head != q.head || c.count > q.count {
+		return ErrStaleCheckpoint
+	}
+	q.release(c.count, q.count-c.count)
+	q.discardBack(q.count - c.count)
+	return nil
+}
@@ -0,0 +1,74 @@
+package queue
+
+import "testing"
+
+func TestQueueRestore(t *testing.T) {
+	q := wrappedQueue(10)
+	c := q.Snapshot()
+
+	for i := 10; i < 15; i++ {
+		q.Add(i)
+	}
+	if err := q.Restore(c); err != nil {
+		t.Fatal("restore failed:", err)
+	}
+	if q.Length() != 10 || q.PeekBack().(int) != 9 {
+		t.Errorf("restore left %v", q)
+	}
+	if n := emptySlots(q); n != q.Capacity()-10 {
+		t.Errorf("restore left %d empty slots", n)
+	}
+	if err := q.Restore(c); err != nil {
+		t.Error("restoring to the current state failed:", err)
+	}
+
+	var removed int
+	q.SetOnRemove(func(interface{}) { removed++ })
+	q.AddAll(10, 11, 12)
+	if err := q.Restore(c); err != nil || removed != 3 {
+		t.Errorf("restore passed %d elements to the hook: %v", removed, err)
+	}
+	q.SetOnRemove(nil)
+
+	q.Remove()
+	if err := q.Restore(c); err != ErrStaleCheckpoint {
+		t.Error("restore after a remove returned", err)
+	}
+
+	c = q.Snapshot()
+	for i := 0; i < 100; i++ {
+		q.Add(i)
+	}
+	if err := q.Restore(c); err != ErrStaleCheckpoint {
+		t.Error("restore after a resize returned", err)
+	}
+	if q.Length() != 109 {
+		t.Error("failed restore changed the length to", q.Length())
+	}
+
+	c = q.Snapshot()
+	q.Reset()
+	if err := q.Restore(c); err != ErrStaleCheckpoint {
+		t.Error("restore after a reset returned", err)
+	}
+
+	// the head comes back to the same slot after a full lap of the buffer
+	r := NewRing(16)
+	r.Fill(16, 0)
+	c = r.Snapshot()
+	r.Fill(16, 1)
+	if err := r.Restore(c); err != ErrStaleCheckpoint {
+		t.Error("restore after a lap of evictions returned", err)
+	}
+	q = New()
+	q.SetAutoShrink(false)
+	q.Fill(16, 0)
+	c = q.Snapshot()
+	for i := 0; i < 16; i++ {
+		q.Remove()
+		q.Add(1)
+	}
+	if err := q.Restore(c); err != ErrStaleCheckpoint {
+		t.Error("restore after a lap of removals returned", err)
+	}
+}
@@ -0,0 +1,56 @@
+package queue
+
+// DedupQueue is a Queue that never holds the same element twice. It keeps a set of the
+// queued elements next to the ring buffer, trading memory for O(1) membership checks.
+// Elements must be valid map keys; adding an uncomparable value panics.
+type DedupQueue struct {
+	q    *Queue
+	seen map[interface{}]struct{}
+}
+
+// NewDedup constructs and returns a new DedupQueue.
+func NewDedup() *DedupQueue {
+	return &DedupQueue{q: New(), seen: make(map[interface{}]struct{})}
+}
+
+// Length returns the number of elements currently stored in the queue.
+func (d *DedupQueue) Length() int {
+	return d.q.Length()
+}
+
+// Contains reports whether elem is currently in the queue.
+func (d *DedupQueue) Contains(elem interface{}) bool {
+	_, ok := d.seen[elem]
+	return ok
+}
+
+// AddUnique puts elem on the end of the queue unless it is already queued, and reports
+// whether it was added.
+func (d *DedupQueue) AddUnique(elem interface{}) bool {
+	if _, ok := d.seen[elem]; ok {
+		return false
+	}
+	d.seen[elem] = struct{}{}
+	d.q.Add(elem)
+	return true
+}
+
+// Peek returns the element at the head of the queue. Like Queue.Peek, it returns garbage
+// if the queue is empty.
+func (d *DedupQueue) Peek() interface{} {
+	return d.q.Peek()
+}
+
+// Remove removes the element from the front of the queue, after which it may be added
+// again. Like Queue.Remove, calling it on an empty queue leaves the queue in a bad state.
+func (d *DedupQueue) Remove() {
+	d.Pop()
+}
+
+// Pop removes and returns the element from the front of the queue, after which it may be
+// added again. Like Remove, it must not be called on an empty queue.
+func (d *DedupQueue) Pop() interface{} {
+	elem := d.q.Pop()
+	delete(d.seen, elem)
+	return elem
+}
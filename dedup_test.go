@@ -0,0 +1,42 @@
+package queue
+
+import "testing"
+
+func TestDedupQueue(t *testing.T) {
+	d := NewDedup()
+
+	for i := 0; i < 100; i++ {
+		if !d.AddUnique(i) {
+			t.Fatal("failed to add", i)
+		}
+	}
+	for i := 0; i < 100; i++ {
+		if d.AddUnique(i) {
+			t.Fatal("added duplicate", i)
+		}
+	}
+	if d.Length() != 100 {
+		t.Error("queue has length", d.Length())
+	}
+
+	for i := 0; i < 50; i++ {
+		if d.Pop().(int) != i {
+			t.Fatal("removed out of order at", i)
+		}
+		if d.Contains(i) {
+			t.Error("removed element still contained", i)
+		}
+	}
+	if !d.AddUnique(0) {
+		t.Error("could not re-add a removed element")
+	}
+	if d.Peek().(int) != 50 {
+		t.Error("peek returned", d.Peek())
+	}
+	for i := 50; i < 100; i++ {
+		d.Remove()
+	}
+	if d.Pop().(int) != 0 || d.Length() != 0 {
+		t.Error("re-added element was not kept at the back")
+	}
+}
@@ -0,0 +1,82 @@
+package queue
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+)
+
+// GobEncode implements gob.GobEncoder, encoding the elements of the queue in order. As with
+// any interface values sent through gob, the concrete types of the elements must be
+// registered with gob.Register.
+func (q *Queue) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(q.ToSlice()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GobDecode implements gob.GobDecoder, replacing the contents of the queue with the decoded
+// elements. If the queue is typed or has a validator, an element it doesn't accept makes
+// GobDecode return an error and leave the queue unchanged.
+func (q *Queue) GobDecode(data []byte) error {
+	var elems []interface{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&elems); err != nil {
+		return err
+	}
+	return q.load(elems)
+}
+
+// MarshalJSON implements json.Marshaler, encoding the queue as an array of its elements in
+// order.
+func (q *Queue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(q.ToSlice())
+}
+
+// UnmarshalJSON implements json.Unmarshaler, replacing the contents of the queue with the
+// elements of a JSON array. The elements are decoded as encoding/json decodes into an
+// interface{}, so numbers become float64, objects map[string]interface{}, and so on. As with
+// GobDecode, an element a typed or validated queue doesn't accept makes it return an error
+// and leave the queue unchanged.
+func (q *Queue) UnmarshalJSON(data []byte) error {
+	var elems []interface{}
+	if err := json.Unmarshal(data, &elems); err != nil {
+		return err
+	}
+	return q.load(elems)
+}
+
+// load replaces the contents of the queue with elems, keeping its configuration, or returns
+// an error without changing the queue if it doesn't accept one of them. A zero Queue is
+// given the configuration New would.
+func (q *Queue) load(elems []interface{}) error {
+	for _, elem := range elems {
+		if err := q.elemError(elem); err != nil {
+			return err
+		}
+	}
+	if q.buf == nil {
+		q.growth = defaultGrowthFactor
+		q.shrink = defaultShrinkRatio
+		q.minLen = minQueueLen
+	}
+	if q.limit > 0 && len(elems) > q.limit {
+		elems = elems[len(elems)-q.limit:]
+	}
+	if q.buf != nil {
+		q.release(0, q.count)
+		q.freeBuf(q.buf)
+	}
+	q.setBuf(q.allocBuf(q.capacityFor(len(elems))))
+	copy(q.buf, elems)
+	q.head = 0
+	q.tail = q.wrap(len(elems))
+	q.removed += uint64(q.count)
+	q.added += uint64(len(elems))
+	q.count = len(elems)
+	q.updateHighWaterMark()
+	q.resizes++
+	q.mods++
+	return nil
+}
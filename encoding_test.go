@@ -0,0 +1,124 @@
+package queue
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestQueueGob(t *testing.T) {
+	q := New()
+	for i := 0; i < 100; i++ {
+		q.Add(i)
+	}
+	q.RemoveN(50)
+	q.Add("mixed")
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(q); err != nil {
+		t.Fatal(err)
+	}
+	var decoded Queue
+	if err := gob.NewDecoder(&buf).Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded.ToSlice(), q.ToSlice()) {
+		t.Errorf("decoded %v, expected %v", &decoded, q)
+	}
+
+	// the decoded queue must be fully usable
+	for i := 0; i < 100; i++ {
+		decoded.Add(i)
+	}
+	decoded.RemoveN(decoded.Length())
+	if decoded.Capacity() != minQueueLen {
+		t.Errorf("decoded queue has capacity %d after draining", decoded.Capacity())
+	}
+
+	if err := decoded.GobDecode([]byte("garbage")); err == nil {
+		t.Error("decoding garbage succeeded")
+	}
+}
+
+func TestQueueJSON(t *testing.T) {
+	q := New()
+
+	data, err := json.Marshal(q)
+	if err != nil || string(data) != "[]" {
+		t.Errorf("empty queue marshalled to %s, %v", data, err)
+	}
+
+	for i := 0; i < 20; i++ {
+		q.Add(i)
+	}
+	q.RemoveN(15)
+	q.Add("a")
+	q.Add(map[string]interface{}{"b": true})
+	data, err = json.Marshal(q)
+	if err != nil || string(data) != `[15,16,17,18,19,"a",{"b":true}]` {
+		t.Errorf("queue marshalled to %s, %v", data, err)
+	}
+
+	var decoded Queue
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	want := []interface{}{15.0, 16.0, 17.0, 18.0, 19.0, "a", map[string]interface{}{"b": true}}
+	if !reflect.DeepEqual(decoded.ToSlice(), want) {
+		t.Errorf("unmarshalled %v, expected %v", decoded.ToSlice(), want)
+	}
+	decoded.Add(1)
+	if decoded.Length() != len(want)+1 {
+		t.Error("unmarshalled queue has length", decoded.Length())
+	}
+
+	if err := json.Unmarshal([]byte(`{"not": "an array"}`), &decoded); err == nil {
+		t.Error("unmarshalling an object succeeded")
+	}
+}
+
+func TestQueueDecodeChecksElements(t *testing.T) {
+	q := NewTyped(0.0)
+	q.Add(1.0)
+	if err := json.Unmarshal([]byte(`["a", 1.5]`), q); err == nil {
+		t.Error("unmarshalling a string into a typed queue succeeded")
+	}
+	if q.Length() != 1 || q.Peek().(float64) != 1 {
+		t.Error("failed unmarshal changed the queue to", q)
+	}
+	if err := json.Unmarshal([]byte(`[2, 3.5]`), q); err != nil || q.Length() != 2 {
+		t.Errorf("unmarshalling valid elements returned %v and left %v", err, q)
+	}
+
+	errBig := errors.New("too big")
+	q = New()
+	q.SetValidator(func(elem interface{}) error {
+		if elem.(int) > 10 {
+			return errBig
+		}
+		return nil
+	})
+	data, err := FromSlice([]interface{}{1, 20}).GobEncode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.GobDecode(data); err != errBig || q.Length() != 0 {
+		t.Errorf("decoding a rejected element returned %v and left %v", err, q)
+	}
+}
+
+func TestQueueDecodeCallsOnRemove(t *testing.T) {
+	q := FromSlice([]interface{}{"a", "b"})
+	var removed []interface{}
+	q.SetOnRemove(func(elem interface{}) { removed = append(removed, elem) })
+	if err := json.Unmarshal([]byte(`[1]`), q); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(removed) != "[a b]" {
+		t.Error("unmarshalling passed", removed, "to the hook")
+	}
+}
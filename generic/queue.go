@@ -0,0 +1,119 @@
+/*
+Package generic provides a type-parameterised version of the ring-buffer queue found in the parent package.
+Elements are stored directly in a []T rather than as interface{} values, so queuing ints or small structs
+does not box each element, which saves an allocation per Add and reduces GC pressure.
+
+Like its parent, the queue implemented here is *not* thread-safe and does not protect you from misuse.
+*/
+package generic
+
+// minQueueLen is the smallest capacity the queue will shrink to. It must be a power of two,
+// as resize only ever doubles or halves it and index arithmetic wraps with a bitmask.
+const minQueueLen = 16
+
+// Queue represents a single instance of the queue data structure.
+type Queue[T any] struct {
+	buf               []T
+	head, tail, count int
+	limit             int // maximum length of a ring, or 0 if the queue grows
+}
+
+// New constructs and returns a new Queue.
+func New[T any]() *Queue[T] {
+	return &Queue[T]{buf: make([]T, minQueueLen)}
+}
+
+// NewRing constructs and returns a new Queue that holds at most capacity elements. Once it
+// is full, each Add overwrites the element at the head of the queue, so the queue always
+// contains the most recently added elements. A ring allocates its buffer once and never
+// resizes it.
+func NewRing[T any](capacity int) *Queue[T] {
+	if capacity < 1 {
+		panic("ring capacity must be at least 1")
+	}
+	n := 1
+	for n < capacity {
+		n <<= 1
+	}
+	return &Queue[T]{buf: make([]T, n), limit: capacity}
+}
+
+// Length returns the number of elements currently stored in the queue.
+func (q *Queue[T]) Length() int {
+	return q.count
+}
+
+func (q *Queue[T]) resize() {
+	newBuf := make([]T, q.count*2)
+
+	if q.tail > q.head {
+		copy(newBuf, q.buf[q.head:q.tail])
+	} else {
+		copy(newBuf, q.buf[q.head:len(q.buf)])
+		copy(newBuf[len(q.buf)-q.head:], q.buf[:q.tail])
+	}
+
+	q.head = 0
+	q.tail = q.count
+	q.buf = newBuf
+}
+
+// Add puts an element on the end of the queue.
+func (q *Queue[T]) Add(elem T) {
+	if q.limit > 0 && q.count == q.limit {
+		q.discard()
+	}
+	if q.count == len(q.buf) {
+		q.resize()
+	}
+
+	q.buf[q.tail] = elem
+	q.tail = (q.tail + 1) & (len(q.buf) - 1)
+	q.count++
+}
+
+// Peek returns the element at the head of the queue. If the queue is empty (Length == 0),
+// Peek does not panic, it simply returns garbage.
+func (q *Queue[T]) Peek() T {
+	return q.buf[q.head]
+}
+
+// Get returns the element at index i in the queue. If the index is invalid, the
+// call will panic.
+func (q *Queue[T]) Get(i int) T {
+	if i >= q.Length() || i < 0 {
+		panic("index out of range")
+	}
+	modi := (q.head + i) & (len(q.buf) - 1)
+	return q.buf[modi]
+}
+
+// Remove removes the element from the front of the queue. If you actually want the element,
+// call Peek first. If the queue is empty (Length == 0), Remove will put the queue in a bad
+// state and all further operations will be undefined.
+func (q *Queue[T]) Remove() {
+	q.discard()
+	if q.limit == 0 && len(q.buf) > minQueueLen && q.count*4 <= len(q.buf) {
+		q.resize()
+	}
+}
+
+// Pop removes the element from the front of the queue and returns it and true, or returns
+// the zero value and false if the queue is empty.
+func (q *Queue[T]) Pop() (T, bool) {
+	if q.count == 0 {
+		var zero T
+		return zero, false
+	}
+	elem := q.buf[q.head]
+	q.Remove()
+	return elem, true
+}
+
+// discard removes the element from the front of the queue, without shrinking it.
+func (q *Queue[T]) discard() {
+	var zero T
+	q.buf[q.head] = zero
+	q.head = (q.head + 1) & (len(q.buf) - 1)
+	q.count--
+}
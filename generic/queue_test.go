@@ -0,0 +1,177 @@
+package generic
+
+import "testing"
+
+func TestQueueLength(t *testing.T) {
+	q := New[int]()
+
+	if q.Length() != 0 {
+		t.Error("empty queue length not 0")
+	}
+
+	for i := 0; i < 1000; i++ {
+		q.Add(i)
+		if q.Length() != i+1 {
+			t.Error("adding: queue with", i, "elements has length", q.Length())
+		}
+	}
+	for i := 0; i < 1000; i++ {
+		q.Remove()
+		if q.Length() != 1000-i-1 {
+			t.Error("removing: queue with", 1000-i-1, "elements has length", q.Length())
+		}
+	}
+}
+
+func TestQueueGet(t *testing.T) {
+	q := New[int]()
+
+	for i := 0; i < 1000; i++ {
+		q.Add(i)
+		for j := 0; j < q.Length(); j++ {
+			if q.Get(j) != j {
+				t.Errorf("index %d doesn't contain %d", j, j)
+			}
+		}
+	}
+}
+
+func TestQueuePeekRemoveOrder(t *testing.T) {
+	q := New[string]()
+
+	for _, s := range []string{"a", "b", "c"} {
+		q.Add(s)
+	}
+	for _, want := range []string{"a", "b", "c"} {
+		if got := q.Peek(); got != want {
+			t.Errorf("peek got %q, want %q", got, want)
+		}
+		q.Remove()
+	}
+}
+
+func TestQueueGetOutOfRangePanics(t *testing.T) {
+	q := New[int]()
+
+	q.Add(1)
+	q.Add(2)
+	q.Add(3)
+
+	for _, i := range []int{-1, 4} {
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("should panic on index %d", i)
+				}
+			}()
+			q.Get(i)
+		}()
+	}
+}
+
+func TestQueuePop(t *testing.T) {
+	q := New[int]()
+	q.Add(1)
+	if v, ok := q.Pop(); !ok || v != 1 {
+		t.Errorf("pop returned %v, %v", v, ok)
+	}
+	if v, ok := q.Pop(); ok || v != 0 {
+		t.Errorf("pop on empty queue returned %v, %v", v, ok)
+	}
+}
+
+func TestNewRing(t *testing.T) {
+	q := NewRing[int](5)
+	buf := q.buf
+	for i := 0; i < 100; i++ {
+		q.Add(i)
+		want := i + 1
+		if want > 5 {
+			want = 5
+		}
+		if q.Length() != want {
+			t.Fatalf("ring holds %d elements after %d adds", q.Length(), i+1)
+		}
+		if q.Peek() != i+1-want {
+			t.Fatalf("ring head is %d after adding %d", q.Peek(), i)
+		}
+	}
+	for want := 95; want < 100; want++ {
+		if v, ok := q.Pop(); !ok || v != want {
+			t.Errorf("pop returned %v, %v, want %d", v, ok, want)
+		}
+	}
+	if _, ok := q.Pop(); ok {
+		t.Error("pop on drained ring succeeded")
+	}
+	if &q.buf[0] != &buf[0] {
+		t.Error("ring was resized")
+	}
+
+	q.Add(1)
+	if allocs := testing.AllocsPerRun(100, func() { q.Add(1) }); allocs != 0 {
+		t.Error("adding to a ring allocated", allocs, "times")
+	}
+}
+
+func TestNewRingBadCapacityPanics(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("should panic on a capacity of 0")
+		}
+	}()
+	NewRing[int](0)
+}
+
+func BenchmarkQueueSerial(b *testing.B) {
+	q := New[int]()
+	for i := 0; i < b.N; i++ {
+		q.Add(i)
+	}
+	for i := 0; i < b.N; i++ {
+		q.Remove()
+	}
+}
+
+func BenchmarkQueueTickTock(b *testing.B) {
+	q := New[int]()
+	for i := 0; i < b.N; i++ {
+		q.Add(i)
+		q.Remove()
+	}
+}
+
+// BenchmarkQueueMillion has a twin of the same name in the parent package; run both
+// with -benchmem to compare the boxed and unboxed queues.
+func BenchmarkQueueMillion(b *testing.B) {
+	for n := 0; n < b.N; n++ {
+		q := New[int]()
+		for i := 0; i < 1000000; i++ {
+			q.Add(i)
+		}
+		for i := 0; i < 1000000; i++ {
+			q.Remove()
+		}
+	}
+}
+
+func BenchmarkRing(b *testing.B) {
+	q := NewRing[int](1024)
+	for i := 0; i < b.N; i++ {
+		q.Add(i)
+	}
+}
+
+// BenchmarkChannelRing measures the usual channel-based stand-in for NewRing, which drops the
+// oldest element to make room when the channel is full.
+func BenchmarkChannelRing(b *testing.B) {
+	c := make(chan int, 1024)
+	for i := 0; i < b.N; i++ {
+		select {
+		case c <- i:
+		default:
+			<-c
+			c <- i
+		}
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/aybabtme/queue
+
+go 1.18
@@ -0,0 +1,117 @@
+package queue
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// IntQueue is a queue of ints, using the same ring buffer design as Queue but storing the
+// ints directly rather than boxed in interface{} values, which saves an allocation for most
+// values added. It is written out by hand so that it is available without generics.
+type IntQueue struct {
+	buf               []int
+	head, tail, count int
+}
+
+// NewIntQueue constructs and returns a new IntQueue.
+func NewIntQueue() *IntQueue {
+	return &IntQueue{buf: make([]int, minQueueLen)}
+}
+
+// Length returns the number of elements currently stored in the queue.
+func (q *IntQueue) Length() int {
+	return q.count
+}
+
+func (q *IntQueue) resize(n int) {
+	newBuf := make([]int, roundCapacity(n, minQueueLen))
+
+	if q.head+q.count <= len(q.buf) {
+		copy(newBuf, q.buf[q.head:q.head+q.count])
+	} else {
+		n := copy(newBuf, q.buf[q.head:])
+		copy(newBuf[n:], q.buf[:q.tail])
+	}
+
+	q.head = 0
+	q.tail = q.count & (len(newBuf) - 1)
+	q.buf = newBuf
+}
+
+// Add puts an element on the end of the queue.
+func (q *IntQueue) Add(elem int) {
+	if q.count == len(q.buf) {
+		q.resize(q.count * 2)
+	}
+
+	q.buf[q.tail] = elem
+	q.tail = (q.tail + 1) & (len(q.buf) - 1)
+	q.count++
+}
+
+// Peek returns the element at the head of the queue. If the queue is empty (Length == 0),
+// Peek does not panic, it simply returns garbage.
+func (q *IntQueue) Peek() int {
+	return q.buf[q.head]
+}
+
+// Get returns the element at index i in the queue. If the index is invalid, the
+// call will panic.
+func (q *IntQueue) Get(i int) int {
+	if i >= q.count || i < 0 {
+		panic("index out of range")
+	}
+	return q.buf[(q.head+i)&(len(q.buf)-1)]
+}
+
+// Remove removes the element from the front of the queue. If the queue is empty
+// (Length == 0), Remove will put the queue in a bad state and all further operations will
+// be undefined.
+func (q *IntQueue) Remove() {
+	q.head = (q.head + 1) & (len(q.buf) - 1)
+	q.count--
+	if len(q.buf) > minQueueLen && q.count*4 <= len(q.buf) {
+		q.resize(q.count * 2)
+	}
+}
+
+// Pop removes and returns the element from the front of the queue and true, or returns 0
+// and false if the queue is empty.
+func (q *IntQueue) Pop() (int, bool) {
+	if q.count == 0 {
+		return 0, false
+	}
+	elem := q.buf[q.head]
+	q.Remove()
+	return elem, true
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler, encoding the queue as its length, as a
+// uvarint, followed by its elements in order, each as 8 big-endian bytes.
+func (q *IntQueue) MarshalBinary() ([]byte, error) {
+	data := make([]byte, binary.MaxVarintLen64+8*q.count)
+	n := binary.PutUvarint(data, uint64(q.count))
+	for i := 0; i < q.count; i++ {
+		binary.BigEndian.PutUint64(data[n:], uint64(q.buf[(q.head+i)&(len(q.buf)-1)]))
+		n += 8
+	}
+	return data[:n], nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler, replacing the contents of the queue
+// with elements encoded by MarshalBinary.
+func (q *IntQueue) UnmarshalBinary(data []byte) error {
+	length, n := binary.Uvarint(data)
+	if n <= 0 || length != uint64(len(data)-n)/8 || (len(data)-n)%8 != 0 {
+		return errors.New("queue: invalid IntQueue encoding")
+	}
+	data = data[n:]
+	q.count = int(length)
+	q.buf = make([]int, roundCapacity(q.count, minQueueLen))
+	for i := 0; i < q.count; i++ {
+		q.buf[i] = int(binary.BigEndian.Uint64(data[8*i:]))
+	}
+	q.head = 0
+	q.tail = q.count & (len(q.buf) - 1)
+	return nil
+}
@@ -0,0 +1,92 @@
+package queue
+
+import "testing"
+
+func TestIntQueue(t *testing.T) {
+	q := NewIntQueue()
+
+	if _, ok := q.Pop(); ok {
+		t.Error("pop on empty queue succeeded")
+	}
+
+	for i := 0; i < 1000; i++ {
+		q.Add(i)
+		if q.Peek() != 0 || q.Get(i) != i {
+			t.Fatal("wrong element after adding", i)
+		}
+	}
+	for i := 0; i < 990; i++ {
+		if v, ok := q.Pop(); !ok || v != i {
+			t.Fatalf("pop returned %d, %v, want %d", v, ok, i)
+		}
+		q.Add(1000 + i)
+	}
+	if q.Length() != 1000 || q.Get(999) != 1989 {
+		t.Error("queue has length", q.Length())
+	}
+	for q.Length() > 0 {
+		q.Remove()
+	}
+	if len(q.buf) != minQueueLen {
+		t.Error("drained queue has capacity", len(q.buf))
+	}
+}
+
+func TestIntQueueGetOutOfRangePanics(t *testing.T) {
+	q := NewIntQueue()
+	q.Add(1)
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("should panic when index is out of range")
+		}
+	}()
+	q.Get(1)
+}
+
+func TestIntQueueMarshalBinary(t *testing.T) {
+	q := NewIntQueue()
+	for i := 0; i < 20; i++ {
+		q.Add(i * -1000)
+	}
+	for i := 0; i < 10; i++ {
+		q.Remove()
+		q.Add(i)
+	}
+
+	data, err := q.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var d IntQueue
+	if err := d.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if d.Length() != q.Length() {
+		t.Fatal("decoded queue has length", d.Length())
+	}
+	for i := 0; i < q.Length(); i++ {
+		if d.Get(i) != q.Get(i) {
+			t.Errorf("index %d decoded as %d, want %d", i, d.Get(i), q.Get(i))
+		}
+	}
+	d.Add(5)
+
+	for _, bad := range [][]byte{nil, {1, 0, 0}, {0, 1}, {0x80}} {
+		if err := d.UnmarshalBinary(bad); err == nil {
+			t.Errorf("decoding %v succeeded", bad)
+		}
+	}
+}
+
+func BenchmarkIntQueueMillion(b *testing.B) {
+	for n := 0; n < b.N; n++ {
+		q := NewIntQueue()
+		for i := 0; i < 1000000; i++ {
+			q.Add(i)
+		}
+		for i := 0; i < 1000000; i++ {
+			q.Remove()
+		}
+	}
+}
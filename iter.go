@@ -0,0 +1,110 @@
+//go:build go1.23
+
+package queue
+
+import (
+	"context"
+	"iter"
+)
+
+// All returns an iterator over the elements of the queue in order, from head to tail. The
+// queue must not be modified while the iteration is in progress.
+func (q *Queue) All() iter.Seq[interface{}] {
+	return func(yield func(interface{}) bool) {
+		for i := 0; i < q.count; i++ {
+			if !yield(q.buf[q.wrap(q.head+i)]) {
+				return
+			}
+		}
+	}
+}
+
+// Backward returns an iterator over the elements of the queue in reverse order, from tail to
+// head. The queue must not be modified while the iteration is in progress.
+func (q *Queue) Backward() iter.Seq[interface{}] {
+	return func(yield func(interface{}) bool) {
+		for i := q.count - 1; i >= 0; i-- {
+			if !yield(q.buf[q.wrap(q.head+i)]) {
+				return
+			}
+		}
+	}
+}
+
+// AllChecked is like All, but panics if the queue is modified while the iteration is in
+// progress, as detected by a change of Version, rather than silently yielding garbage. It
+// is meant for tracking down misuse; All is faster.
+func (q *Queue) AllChecked() iter.Seq[interface{}] {
+	return func(yield func(interface{}) bool) {
+		mods := q.mods
+		for i := 0; i < q.count; i++ {
+			if !yield(q.buf[q.wrap(q.head+i)]) {
+				return
+			}
+			if q.mods != mods {
+				panic("queue modified during iteration")
+			}
+		}
+	}
+}
+
+// IterFrom returns an iterator over the elements of the queue and their indexes, in order,
+// starting from index start rather than the head. A start equal to Length() yields nothing;
+// any other invalid start makes the call panic. The queue must not be modified while the
+// iteration is in progress.
+func (q *Queue) IterFrom(start int) iter.Seq2[int, interface{}] {
+	if start > q.count || start < 0 {
+		panic("index out of range")
+	}
+	return func(yield func(int, interface{}) bool) {
+		for i := start; i < q.count; i++ {
+			if !yield(i, q.buf[q.wrap(q.head+i)]) {
+				return
+			}
+		}
+	}
+}
+
+// Chunks returns an iterator over the elements of the queue in order, from head to tail, in
+// slices of size elements; the last slice holds the rest and may be shorter. Each slice is
+// copied into a single scratch buffer that the iterator reuses, so it is only valid until
+// the next one is yielded and must be copied to be kept. It panics if size is less than 1.
+// The queue must not be modified while the iteration is in progress.
+func (q *Queue) Chunks(size int) iter.Seq[[]interface{}] {
+	if size < 1 {
+		panic("chunk size must be at least 1")
+	}
+	return func(yield func([]interface{}) bool) {
+		if q.count == 0 {
+			return
+		}
+		chunk := make([]interface{}, size)
+		if size > q.count {
+			chunk = make([]interface{}, q.count)
+		}
+		for i := 0; i < q.count; i += len(chunk) {
+			n := 0
+			for ; n < len(chunk) && i+n < q.count; n++ {
+				chunk[n] = q.buf[q.wrap(q.head+i+n)]
+			}
+			if !yield(chunk[:n]) {
+				return
+			}
+		}
+	}
+}
+
+// Consume returns an iterator that pops and yields elements from the head of the queue as
+// they become available, waiting while the queue is empty. The iteration ends when ctx is
+// done or the loop body breaks out of it; an element is only popped once the iterator is
+// about to yield it, so none are lost when the loop stops.
+func (b *BlockingQueue) Consume(ctx context.Context) iter.Seq[interface{}] {
+	return func(yield func(interface{}) bool) {
+		for {
+			elem, err := b.Pop(ctx)
+			if err != nil || !yield(elem) {
+				return
+			}
+		}
+	}
+}
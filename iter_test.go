@@ -0,0 +1,226 @@
+//go:build go1.23
+
+package queue
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestQueueAll(t *testing.T) {
+	q := New()
+
+	for range q.All() {
+		t.Error("empty queue yielded an element")
+	}
+
+	// shift the head along so that the live range wraps around the buffer
+	for i := 0; i < minQueueLen; i++ {
+		q.Add(i)
+	}
+	for i := 0; i < minQueueLen/2; i++ {
+		q.Remove()
+		q.Add(minQueueLen + i)
+	}
+
+	i := 0
+	for v := range q.All() {
+		if v != q.Get(i) {
+			t.Errorf("iteration %d yielded %v, expected %v", i, v, q.Get(i))
+		}
+		i++
+	}
+	if i != q.Length() {
+		t.Errorf("iterated over %d elements, expected %d", i, q.Length())
+	}
+
+	i = 0
+	for range q.All() {
+		i++
+		if i == 3 {
+			break
+		}
+	}
+	if i != 3 {
+		t.Errorf("break stopped after %d elements", i)
+	}
+	if q.Length() != minQueueLen {
+		t.Error("iteration changed queue length to", q.Length())
+	}
+}
+
+func TestQueueBackward(t *testing.T) {
+	q := New()
+
+	for range q.Backward() {
+		t.Error("empty queue yielded an element")
+	}
+
+	// shift the head along so that tail ends up below head in the buffer
+	for i := 0; i < minQueueLen; i++ {
+		q.Add(i)
+	}
+	q.RemoveN(minQueueLen / 2)
+	for i := 0; i < minQueueLen/4; i++ {
+		q.Add(minQueueLen + i)
+	}
+
+	i := q.Length() - 1
+	for v := range q.Backward() {
+		if v != q.Get(i) {
+			t.Errorf("iteration yielded %v, expected %v", v, q.Get(i))
+		}
+		i--
+	}
+	if i != -1 {
+		t.Errorf("iteration stopped before index %d", i)
+	}
+
+	for v := range q.Backward() {
+		if v != q.PeekBack() {
+			t.Errorf("first element yielded is %v, expected %v", v, q.PeekBack())
+		}
+		break
+	}
+}
+
+func TestQueueAllChecked(t *testing.T) {
+	q := wrappedQueue(10)
+
+	i := 0
+	for v := range q.AllChecked() {
+		if v.(int) != i {
+			t.Errorf("iteration %d yielded %v", i, v)
+		}
+		i++
+	}
+	if i != 10 {
+		t.Error("iterated over", i, "elements")
+	}
+
+	mutations := []func(){
+		func() { q.Add(1) },
+		func() { q.Remove() },
+		func() { q.AddFront(1) },
+		func() { q.RemoveBack() },
+		func() { q.Clear() },
+	}
+	for i, mutate := range mutations {
+		q = wrappedQueue(10)
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("mutation %d during iteration did not panic", i)
+				}
+			}()
+			for range q.AllChecked() {
+				mutate()
+			}
+		}()
+	}
+}
+
+func TestQueueIterFrom(t *testing.T) {
+	q := wrappedQueue(10)
+
+	want := 4
+	for i, v := range q.IterFrom(4) {
+		if i != want || v.(int) != want {
+			t.Errorf("iteration yielded %d, %v, want %d", i, v, want)
+		}
+		want++
+		if i == 7 {
+			break
+		}
+	}
+	if want != 8 {
+		t.Error("iteration stopped before", want)
+	}
+	for range q.IterFrom(10) {
+		t.Error("iteration from the length yielded an element")
+	}
+
+	for _, start := range []int{-1, 11} {
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("should panic iterating from %d", start)
+				}
+			}()
+			q.IterFrom(start)
+		}()
+	}
+}
+
+func TestQueueChunks(t *testing.T) {
+	q := wrappedQueue(10)
+
+	var got [][]interface{}
+	for chunk := range q.Chunks(4) {
+		got = append(got, append([]interface{}(nil), chunk...))
+	}
+	if fmt.Sprint(got) != "[[0 1 2 3] [4 5 6 7] [8 9]]" {
+		t.Error("chunks of 4 were", got)
+	}
+
+	got = got[:0]
+	for chunk := range q.Chunks(100) {
+		got = append(got, chunk)
+	}
+	if len(got) != 1 || len(got[0]) != 10 {
+		t.Error("oversized chunks were", got)
+	}
+
+	n := 0
+	for range q.Chunks(3) {
+		if n++; n == 2 {
+			break
+		}
+	}
+	if n != 2 {
+		t.Error("iteration didn't stop after", n, "chunks")
+	}
+	for chunk := range New().Chunks(3) {
+		t.Error("empty queue yielded", chunk)
+	}
+}
+
+func TestBlockingQueueConsume(t *testing.T) {
+	b := NewBlocking(2)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		for i := 0; i < 10; i++ {
+			b.Push(context.Background(), i)
+		}
+	}()
+	i := 0
+	for v := range b.Consume(ctx) {
+		if v.(int) != i {
+			t.Errorf("consumed %v, expected %d", v, i)
+		}
+		if i++; i == 5 {
+			break
+		}
+	}
+	for v := range b.Consume(ctx) {
+		if v.(int) != i {
+			t.Errorf("consumed %v after resuming, expected %d", v, i)
+		}
+		if i++; i == 10 {
+			cancel()
+		}
+	}
+	if i != 10 || b.Length() != 0 {
+		t.Errorf("consumed %d elements, %d left", i, b.Length())
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	for v := range b.Consume(ctx) {
+		t.Error("consumed", v, "from an empty queue")
+	}
+}
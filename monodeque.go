@@ -0,0 +1,60 @@
+package queue
+
+// MonoDeque tracks the minimum of a sliding window in amortized O(1) time, using the classic
+// monotonic deque. Pass a less function to track the minimum, or a greater-than function to
+// track the maximum.
+//
+// Every pushed value gets a sequence number, starting at 0. The window is defined by the
+// caller through PopExpired, which forgets all values pushed before a given sequence number.
+type MonoDeque struct {
+	q    *Queue
+	less func(a, b interface{}) bool
+	next int
+}
+
+type monoEntry struct {
+	seq   int
+	value interface{}
+}
+
+// NewMonoDeque constructs and returns a new MonoDeque ordered by less.
+func NewMonoDeque(less func(a, b interface{}) bool) *MonoDeque {
+	return &MonoDeque{q: New(), less: less}
+}
+
+// Length returns the number of values the deque still holds. This is usually less than the
+// size of the window, since values that can never be the extreme are dropped on Push.
+func (m *MonoDeque) Length() int {
+	return m.q.Length()
+}
+
+// Push adds value to the window and returns its sequence number. Any held value that is not
+// less than value is dropped first, since it can no longer be the extreme of any window that
+// includes value.
+func (m *MonoDeque) Push(value interface{}) int {
+	for m.q.Length() > 0 && !m.less(m.q.PeekBack().(monoEntry).value, value) {
+		m.q.RemoveBack()
+	}
+	seq := m.next
+	m.next++
+	m.q.Add(monoEntry{seq: seq, value: value})
+	return seq
+}
+
+// Front returns the extreme value of the current window and true, or nil and false if the
+// deque is empty.
+func (m *MonoDeque) Front() (interface{}, bool) {
+	e, ok := m.q.TryPeek()
+	if !ok {
+		return nil, false
+	}
+	return e.(monoEntry).value, true
+}
+
+// PopExpired forgets all values whose sequence number is less than oldest, that is, the ones
+// that have slid out of the window.
+func (m *MonoDeque) PopExpired(oldest int) {
+	for m.q.Length() > 0 && m.q.Peek().(monoEntry).seq < oldest {
+		m.q.Remove()
+	}
+}
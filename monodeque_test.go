@@ -0,0 +1,53 @@
+package queue
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestMonoDeque(t *testing.T) {
+	const window = 5
+	less := func(a, b interface{}) bool { return a.(int) < b.(int) }
+	m := NewMonoDeque(less)
+
+	if _, ok := m.Front(); ok {
+		t.Error("front of empty deque succeeded")
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	values := make([]int, 1000)
+	for i := range values {
+		values[i] = rng.Intn(100)
+		if seq := m.Push(values[i]); seq != i {
+			t.Fatalf("push %d returned sequence %d", i, seq)
+		}
+		m.PopExpired(i - window + 1)
+
+		want := values[i]
+		for j := i - 1; j >= 0 && j > i-window; j-- {
+			if values[j] < want {
+				want = values[j]
+			}
+		}
+		if v, ok := m.Front(); !ok || v.(int) != want {
+			t.Fatalf("window ending at %d has min %v, want %d", i, v, want)
+		}
+		if m.Length() > window {
+			t.Fatal("deque holds more than the window", m.Length())
+		}
+	}
+}
+
+func TestMonoDequeMax(t *testing.T) {
+	m := NewMonoDeque(func(a, b interface{}) bool { return a.(int) > b.(int) })
+	for _, v := range []int{1, 3, 2, 5, 4} {
+		m.Push(v)
+	}
+	if v, _ := m.Front(); v.(int) != 5 {
+		t.Error("max is", v)
+	}
+	m.PopExpired(4)
+	if v, _ := m.Front(); v.(int) != 4 {
+		t.Error("max after expiring is", v)
+	}
+}
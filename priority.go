@@ -0,0 +1,75 @@
+package queue
+
+// PriorityQueue is a binary min-heap kept directly in the index space of a Queue, so that
+// Pop always returns the smallest element according to less. Elements that are equally
+// small come out in no particular order.
+type PriorityQueue struct {
+	q    *Queue
+	less func(a, b interface{}) bool
+}
+
+// NewPriority constructs and returns a new PriorityQueue ordered by less.
+func NewPriority(less func(a, b interface{}) bool) *PriorityQueue {
+	return &PriorityQueue{q: New(), less: less}
+}
+
+// Length returns the number of elements currently stored in the queue.
+func (p *PriorityQueue) Length() int {
+	return p.q.Length()
+}
+
+// Push adds an element to the queue.
+func (p *PriorityQueue) Push(elem interface{}) {
+	p.q.Add(elem)
+	p.up(p.q.Length() - 1)
+}
+
+// Peek returns the smallest element of the queue and true, or nil and false if the queue is
+// empty.
+func (p *PriorityQueue) Peek() (interface{}, bool) {
+	return p.q.TryPeek()
+}
+
+// Pop removes and returns the smallest element of the queue and true, or returns nil and
+// false if the queue is empty.
+func (p *PriorityQueue) Pop() (interface{}, bool) {
+	n := p.q.Length() - 1
+	if n < 0 {
+		return nil, false
+	}
+	p.q.Swap(0, n)
+	elem := p.q.PeekBack()
+	p.q.RemoveBack()
+	p.down(0)
+	return elem, true
+}
+
+// up moves the element at index i towards the root until its parent is no larger.
+func (p *PriorityQueue) up(i int) {
+	for i > 0 {
+		parent := (i - 1) / 2
+		if !p.less(p.q.Get(i), p.q.Get(parent)) {
+			return
+		}
+		p.q.Swap(i, parent)
+		i = parent
+	}
+}
+
+// down moves the element at index i towards the leaves until neither child is smaller.
+func (p *PriorityQueue) down(i int) {
+	n := p.q.Length()
+	for {
+		smallest := i
+		for _, child := range [2]int{2*i + 1, 2*i + 2} {
+			if child < n && p.less(p.q.Get(child), p.q.Get(smallest)) {
+				smallest = child
+			}
+		}
+		if smallest == i {
+			return
+		}
+		p.q.Swap(i, smallest)
+		i = smallest
+	}
+}
@@ -0,0 +1,40 @@
+package queue
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestPriorityQueue(t *testing.T) {
+	p := NewPriority(func(a, b interface{}) bool { return a.(int) < b.(int) })
+
+	if _, ok := p.Pop(); ok {
+		t.Error("pop on empty queue succeeded")
+	}
+	if _, ok := p.Peek(); ok {
+		t.Error("peek on empty queue succeeded")
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	var want []int
+	for i := 0; i < 1000; i++ {
+		v := rng.Intn(500)
+		want = append(want, v)
+		p.Push(v)
+	}
+	sort.Ints(want)
+
+	if v, ok := p.Peek(); !ok || v.(int) != want[0] {
+		t.Errorf("peek returned %v, %v", v, ok)
+	}
+	for i, w := range want {
+		v, ok := p.Pop()
+		if !ok || v.(int) != w {
+			t.Fatalf("pop %d returned %v, %v, want %d", i, v, ok, w)
+		}
+	}
+	if p.Length() != 0 {
+		t.Error("drained queue has length", p.Length())
+	}
+}
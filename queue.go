@@ -9,17 +9,183 @@ queue (such as trying to remove an element from an empty queue) then who knows w
 */
 package queue
 
-const minQueueLen = 16
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"reflect"
+	"strings"
+	"unsafe"
+)
+
+const (
+	// minQueueLen is the default smallest capacity of a queue. It must be a power of two,
+	// see roundCapacity.
+	minQueueLen = 16
+	// maxCapacity is the largest capacity of a queue: the largest power of two an int can
+	// hold, so that doubling it or adding to a count below it never overflows.
+	maxCapacity = int(^uint(0)>>2) + 1
+
+	defaultGrowthFactor = 2.0
+	defaultShrinkRatio  = 0.25
+)
 
 // Queue represents a single instance of the queue data structure.
 type Queue struct {
 	buf               []interface{}
+	mask              int // len(buf)-1 when len(buf) is a power of two, or -1, see wrap
 	head, tail, count int
+	noShrink          bool
+	growth, shrink    float64
+	hysteresis, below int // removals needed below the shrink ratio before shrinking, and so far
+	limit             int // maximum count for a ring, or 0 if unbounded
+	maxCap            int // maximum capacity, a power of two, or 0 if unbounded
+	threshold         int // capacity above which the queue grows by half, or 0, see SetGrowthThreshold
+	minLen            int
+	resizes           int
+	added, removed    uint64 // elements ever added and removed, for Stats
+	popped            uint64 // elements ever removed from the front, for Restore
+	maxCount          int
+	mods              uint64 // bumped by every change to the queue, see Version
+	onResize          func(oldCap, newCap int)
+	onEvict           func(evicted interface{})
+	onRemove          func(elem interface{})
+	noZero            bool
+	prefault          bool
+	typ               reflect.Type // type of every element, or nil if unchecked
+	validate          func(elem interface{}) error
+	allocator         Allocator
 }
 
 // New constructs and returns a new Queue.
 func New() *Queue {
-	return &Queue{buf: make([]interface{}, minQueueLen)}
+	return NewWithCapacity(minQueueLen)
+}
+
+// NewWithCapacity constructs and returns a new Queue that can hold at least n elements
+// before it has to grow. It is useful when the number of elements is known up front, as it
+// avoids the repeated resizing a queue from New would go through to reach that size.
+func NewWithCapacity(n int) *Queue {
+	n = roundCapacity(n, minQueueLen)
+	return &Queue{
+		buf:    make([]interface{}, n),
+		mask:   n - 1,
+		growth: defaultGrowthFactor,
+		shrink: defaultShrinkRatio,
+		minLen: minQueueLen,
+	}
+}
+
+// NewWithMinLen constructs and returns a new Queue whose capacity never drops below minLen,
+// rounded up to a power of two, instead of the default of 16. Setting a small minimum saves
+// memory when there are many queues that rarely hold more than a few elements. minLen must
+// be at least 1.
+func NewWithMinLen(minLen int) *Queue {
+	if minLen < 1 {
+		panic("minimum length must be at least 1")
+	}
+	minLen = roundCapacity(minLen, 1)
+	return &Queue{
+		buf:    make([]interface{}, minLen),
+		mask:   minLen - 1,
+		growth: defaultGrowthFactor,
+		shrink: defaultShrinkRatio,
+		minLen: minLen,
+	}
+}
+
+// NewWithPolicy constructs and returns a new Queue with a custom resizing policy. When the
+// queue is full its backing buffer is grown by growthFactor, which must be greater than 1.
+// When removing an element leaves the queue at or below shrinkRatio of its capacity, the
+// buffer is shrunk; shrinkRatio must be between 0 and 1. New uses a growthFactor of 2 and a
+// shrinkRatio of 0.25. Since capacities are powers of two, the grown size is rounded up to
+// one, which means growth factors below 2 behave like 2; see SetGrowthThreshold for growing
+// large queues by less.
+func NewWithPolicy(growthFactor, shrinkRatio float64) *Queue {
+	if !(growthFactor > 1) {
+		panic("growth factor must be greater than 1")
+	}
+	if !(shrinkRatio > 0 && shrinkRatio < 1) {
+		panic("shrink ratio must be between 0 and 1")
+	}
+	q := New()
+	q.growth = growthFactor
+	q.shrink = shrinkRatio
+	return q
+}
+
+// FromSlice constructs and returns a new Queue holding the elements of s, with s[0] at the
+// head. The elements are copied, so later changes to s do not affect the queue.
+func FromSlice(s []interface{}) *Queue {
+	q := NewWithCapacity(len(s))
+	copy(q.buf, s)
+	q.tail = q.wrap(len(s))
+	q.count = len(s)
+	q.maxCount = len(s)
+	q.added = uint64(len(s))
+	return q
+}
+
+// Concat constructs and returns a new Queue holding the elements of each of qs in turn, in
+// order, sized for all of them at once. The source queues are left unchanged.
+func Concat(qs ...*Queue) *Queue {
+	n := 0
+	for _, src := range qs {
+		n += src.count
+	}
+	q := NewWithCapacity(n)
+	for _, src := range qs {
+		first, second := src.segments()
+		q.copyIn(first)
+		q.copyIn(second)
+	}
+	return q
+}
+
+// Flatten constructs and returns a new Queue holding the elements of each of the queues
+// held by q in turn, in order, as Concat would. Every element of q must be a *Queue; any
+// other element makes Flatten panic. q and the queues it holds are left unchanged.
+func Flatten(q *Queue) *Queue {
+	qs := make([]*Queue, q.count)
+	for i := range qs {
+		src, ok := q.buf[q.wrap(q.head+i)].(*Queue)
+		if !ok {
+			panic(fmt.Sprintf("cannot flatten element %d of type %T", i, q.buf[q.wrap(q.head+i)]))
+		}
+		qs[i] = src
+	}
+	return Concat(qs...)
+}
+
+// NewRing constructs and returns a new Queue that holds at most capacity elements. Once it
+// is full, each Add evicts the element at the head of the queue to make room, so the queue
+// always contains the most recently added elements. A ring allocates its buffer once and
+// never resizes it. Use SetOnEvict to find out which elements are evicted.
+func NewRing(capacity int) *Queue {
+	if capacity < 1 {
+		panic("ring capacity must be at least 1")
+	}
+	q := NewWithCapacity(capacity)
+	q.noShrink = true
+	q.limit = capacity
+	q.minLen = len(q.buf)
+	return q
+}
+
+// NewFixed constructs and returns a new Queue whose backing buffer is allocated once, to
+// hold capacity elements rounded up to a power of two, and is never resized as elements are
+// added and removed, so that the queue doesn't allocate at all after construction. Once it
+// is full, Add and the other methods that add elements panic, while AddCapped and AddIfRoom
+// return false. Compact and ShrinkTo leave the buffer alone too, since the queue's minimum
+// length is its capacity.
+func NewFixed(capacity int) *Queue {
+	if capacity < 1 {
+		panic("fixed queue capacity must be at least 1")
+	}
+	q := NewWithMinLen(capacity)
+	q.noShrink = true
+	q.maxCap = len(q.buf)
+	return q
 }
 
 // Length returns the number of elements currently stored in the queue.
@@ -27,30 +193,754 @@ func (q *Queue) Length() int {
 	return q.count
 }
 
-func (q *Queue) resize() {
-	newBuf := make([]interface{}, q.count*2)
+// SetAutoShrink controls whether the queue shrinks its backing buffer as elements are
+// removed. Shrinking is enabled by default; disabling it means the buffer only ever grows,
+// which avoids repeated resizing for queues whose length hovers around the shrink threshold.
+func (q *Queue) SetAutoShrink(enabled bool) {
+	q.noShrink = !enabled
+}
+
+// SetShrinkHysteresis makes the queue wait until n removals in a row have found it at or
+// below the shrink ratio before shrinking, rather than shrinking on the first. This keeps a
+// queue whose length keeps crossing the threshold from resizing back and forth, while one
+// that has really drained still releases its memory. An n of 0 or 1, the default, shrinks
+// right away. It panics if n is negative.
+func (q *Queue) SetShrinkHysteresis(n int) {
+	if n < 0 {
+		panic("shrink hysteresis must not be negative")
+	}
+	q.hysteresis = n
+	q.below = 0
+}
+
+// SetMaxCapacity stops the backing buffer from growing past max elements, so that adding to
+// a queue fed by an untrusted producer can't use unbounded memory. Once the queue can't grow
+// any further, Add and the other methods that add elements panic, while AddCapped returns
+// false. Since capacities are powers of two, the buffer actually stops at the largest power
+// of two that is not more than max; a queue that already has a larger buffer keeps it but
+// doesn't grow. A max of 0 removes the limit. It panics if max is negative.
+func (q *Queue) SetMaxCapacity(max int) {
+	if max < 0 {
+		panic("maximum capacity must not be negative")
+	}
+	q.maxCap = 0
+	if max > 0 {
+		q.maxCap = roundCapacity(max, 1)
+		if q.maxCap > max {
+			q.maxCap >>= 1
+		}
+	}
+}
+
+// SetOnRemove registers fn to be called with each element the queue discards, just before
+// its slot is cleared, so that queues of pooled objects can hand them back or reset them.
+// It is called by the methods that remove elements without returning them: Remove,
+// RemoveN, RemoveBack, RemoveBackN, RemoveAt, RemoveUntil, Truncate, TruncateFront, Clear,
+// Reset, Filter, ProcessAndRemove, Distinct and Restore, and by GobDecode and UnmarshalJSON
+// for the elements they replace. Elements returned to the caller, such as by Pop, or evicted
+// from a ring, which SetOnEvict covers, are not passed to fn. A nil fn, the default, removes
+// the hook. fn must not modify the queue.
+func (q *Queue) SetOnRemove(fn func(elem interface{})) {
+	q.onRemove = fn
+}
+
+// SetGrowthThreshold makes the queue grow by half, rather than by its growth factor, once
+// its capacity has reached threshold, so that a single grow of a very large queue doesn't
+// allocate as much slack. Above the threshold the capacity is no longer rounded up to a
+// power of two, which makes indexing a little slower, since it takes a modulus instead of a
+// bitmask. Shrinking back below the threshold restores both, as does raising or clearing the
+// threshold past the current capacity, which rounds the capacity up to a power of two. A
+// threshold of 0, the default, keeps the growth factor at every size. It panics if threshold
+// is negative.
+func (q *Queue) SetGrowthThreshold(threshold int) {
+	if threshold < 0 {
+		panic("growth threshold must not be negative")
+	}
+	q.threshold = threshold
+	if !q.allowsCap(len(q.buf)) {
+		q.resize(len(q.buf))
+	}
+}
+
+// allowsCap reports whether q can have a backing buffer of length n. Lengths that aren't
+// powers of two only come from growing past the growth threshold.
+func (q *Queue) allowsCap(n int) bool {
+	return n&(n-1) == 0 || q.threshold > 0 && n > q.threshold
+}
+
+// SetPrefault controls whether the queue writes to every memory page of a new backing buffer
+// as soon as it is allocated. The operating system hands out the memory for a large buffer
+// lazily, so the first write to each page faults; prefaulting moves that cost into the
+// resize, rather than spreading it over the adds that follow. It is disabled by default, and
+// only worth enabling for large, latency-sensitive queues.
+func (q *Queue) SetPrefault(enabled bool) {
+	q.prefault = enabled
+}
+
+// SetOnResize registers fn to be called each time the queue reallocates its backing buffer,
+// with the capacities before and after. Passing nil removes any registered function.
+func (q *Queue) SetOnResize(fn func(oldCap, newCap int)) {
+	q.onResize = fn
+}
+
+// SetOnEvict registers fn to be called with each element a ring evicts to make room for a
+// new one, so that it can be cleaned up, just before its slot is reused. fn must not modify
+// the queue. Passing nil removes any registered function, after which evicted elements are
+// silently dropped.
+func (q *Queue) SetOnEvict(fn func(evicted interface{})) {
+	q.onEvict = fn
+}
+
+// SetZeroOnRemove controls whether removing an element clears its slot in the backing
+// buffer. Clearing is enabled by default so that removed elements can be garbage collected.
+// Disabling it saves a store per removal, but the queue then keeps every removed element
+// alive until its slot is reused or the buffer is resized; since even an int is boxed when
+// stored in an interface{}, this only makes sense when retaining removed values is
+// harmless, such as for small values held in bulk.
+func (q *Queue) SetZeroOnRemove(enabled bool) {
+	q.noZero = !enabled
+}
+
+// Capacity returns the number of elements the queue can hold before it has to grow its
+// backing buffer.
+func (q *Queue) Capacity() int {
+	return len(q.buf)
+}
+
+// Full reports whether the backing buffer is full, so that the next Add will have to grow
+// it. Calling Grow ahead of time, away from the hot path, avoids paying for that copy in Add.
+// A ring never grows, so use AddIfRoom to find out whether an Add would evict instead.
+func (q *Queue) Full() bool {
+	return q.count == len(q.buf)
+}
+
+// HeadIndex returns the position in the backing buffer of the element at the head of the
+// queue. Together with TailIndex and Capacity it describes where the elements sit, for
+// debugging or building custom bulk operations; see also IsWrapped.
+func (q *Queue) HeadIndex() int {
+	return q.head
+}
+
+// IsWrapped reports whether the elements of the queue wrap around the end of the backing
+// buffer, so that they occupy two separate runs of it rather than one. A full buffer whose
+// head is at index 0 is not wrapped, even though its head and tail indexes are equal.
+func (q *Queue) IsWrapped() bool {
+	return q.head+q.count > len(q.buf)
+}
+
+// TailIndex returns the position in the backing buffer where the next element added to the
+// end of the queue will go.
+func (q *Queue) TailIndex() int {
+	return q.tail
+}
+
+// Version returns a counter that changes every time the queue is modified, whether elements
+// are added, removed, replaced or moved, or the backing buffer is resized. Comparing it with
+// an earlier value is a cheap way to tell whether anything has changed since.
+func (q *Queue) Version() uint64 {
+	return q.mods
+}
+
+// HighWaterMark returns the largest number of elements the queue has held at once, which is
+// the capacity to preallocate with NewWithCapacity for a queue used the same way.
+func (q *Queue) HighWaterMark() int {
+	return q.maxCount
+}
 
+// updateHighWaterMark records the current count if it is the largest so far. It must be
+// called whenever elements are added.
+func (q *Queue) updateHighWaterMark() {
+	if q.count > q.maxCount {
+		q.maxCount = q.count
+	}
+}
+
+// ResizeCount returns the number of times the queue has reallocated its backing buffer,
+// whether to grow or to shrink it.
+func (q *Queue) ResizeCount() int {
+	return q.resizes
+}
+
+// copyOut copies up to len(dst) elements from the front of the queue into dst, in order,
+// and returns the number of elements copied.
+func (q *Queue) copyOut(dst []interface{}) int {
+	if len(dst) > q.count {
+		dst = dst[:q.count]
+	}
 	if q.tail > q.head {
-		copy(newBuf, q.buf[q.head:q.tail])
-	} else {
-		copy(newBuf, q.buf[q.head:len(q.buf)])
-		copy(newBuf[len(q.buf)-q.head:], q.buf[:q.tail])
+		return copy(dst, q.buf[q.head:q.tail])
+	}
+	n := copy(dst, q.buf[q.head:len(q.buf)])
+	return n + copy(dst[n:], q.buf[:q.tail])
+}
+
+// roundCapacity returns the buffer length used to hold n elements: the smallest power of
+// two that is at least n, and never less than min, which must itself be a power of two.
+// Keeping the length a power of two lets index arithmetic wrap with a bitmask instead of a
+// modulus. It panics if n is more than maxCapacity, or if min is less than 1.
+func roundCapacity(n, min int) int {
+	if n > maxCapacity {
+		panic("capacity too large")
+	}
+	if min < 1 {
+		panic("minimum capacity must be at least 1")
+	}
+	c := min
+	for c < n {
+		c <<= 1
+	}
+	return c
+}
+
+// capacityFor returns the buffer length the queue uses to hold n elements. A zero Queue has
+// no minimum length set, and uses the default. Above the growth threshold, the length is n
+// itself rather than a power of two.
+func (q *Queue) capacityFor(n int) int {
+	if q.threshold > 0 && n > q.threshold && n > q.minLen {
+		if n > maxCapacity {
+			panic("capacity too large")
+		}
+		return n
+	}
+	if q.minLen == 0 {
+		return roundCapacity(n, minQueueLen)
+	}
+	return roundCapacity(n, q.minLen)
+}
+
+// wrap returns the slot of the backing buffer that index i, counted from the start of the
+// buffer and possibly past either end of it, falls on. Capacities are powers of two apart
+// from above the growth threshold, so the usual case is a bitmask rather than a modulus.
+func (q *Queue) wrap(i int) int {
+	if q.mask >= 0 {
+		return i & q.mask
+	}
+	return wrapSlow(i, len(q.buf))
+}
+
+// setBuf makes buf the backing buffer of the queue, and sets the mask wrap uses for it.
+func (q *Queue) setBuf(buf []interface{}) {
+	q.buf = buf
+	if q.mask = len(buf) - 1; len(buf)&q.mask != 0 {
+		q.mask = -1
+	}
+}
+
+// wrapSlow is wrap for a buffer of length n that isn't a power of two.
+func wrapSlow(i, n int) int {
+	if i %= n; i < 0 {
+		i += n
+	}
+	return i
+}
+
+// resize moves the elements of the queue into a new backing buffer that can hold at least n
+// elements, which must be at least count.
+func (q *Queue) resize(n int) {
+	if q.maxCap > 0 && q.capacityFor(n) > q.maxCap && q.capacityFor(n) > len(q.buf) {
+		panic("queue is at its maximum capacity")
+	}
+	newBuf := q.allocBuf(q.capacityFor(n))
+	oldCap := len(q.buf)
+
+	q.copyOut(newBuf)
+	q.freeBuf(q.buf)
+	if q.prefault {
+		prefault(newBuf[q.count:])
 	}
 
 	q.head = 0
-	q.tail = q.count
-	q.buf = newBuf
+	q.setBuf(newBuf)
+	q.tail = q.wrap(q.count)
+	q.resizes++
+	q.mods++
+
+	if q.onResize != nil {
+		q.onResize(oldCap, len(newBuf))
+	}
+}
+
+// prefault writes to one slot in each memory page of s, so that the pages are faulted in.
+func prefault(s []interface{}) {
+	step := os.Getpagesize() / int(unsafe.Sizeof(interface{}(nil)))
+	if step < 1 {
+		step = 1
+	}
+	for i := 0; i < len(s); i += step {
+		s[i] = nil
+	}
+}
+
+// grow resizes the backing buffer by the growth factor, or by half above the growth
+// threshold, making room for at least one more element.
+func (q *Queue) grow() {
+	q.resize(q.growTarget())
 }
 
-// Add puts an element on the end of the queue.
+// growTarget returns the number of elements grow resizes the backing buffer for.
+func (q *Queue) growTarget() int {
+	factor := q.growth
+	if q.threshold > 0 && len(q.buf) >= q.threshold {
+		factor = 1.5
+	}
+	n := scaleCapacity(len(q.buf), factor)
+	if q.maxCap > 0 && n > q.maxCap {
+		n = q.maxCap
+	}
+	if n <= len(q.buf) {
+		n = len(q.buf) + 1
+	}
+	return n
+}
+
+// NextGrowCapacity returns the capacity the backing buffer will grow to the next time an
+// element is added to a full queue. It returns the current capacity if the queue can't grow,
+// because it is a ring or has reached the limit set by SetMaxCapacity.
+func (q *Queue) NextGrowCapacity() int {
+	if q.limit > 0 || q.maxCap > 0 && len(q.buf) >= q.maxCap {
+		return len(q.buf)
+	}
+	return q.capacityFor(q.growTarget())
+}
+
+// Compact shrinks the backing buffer to the smallest capacity that holds the current
+// elements, releasing the slack left behind after a spike, and returns the number of slots
+// freed. It does nothing and returns 0 if the buffer is already as small as it can be.
+func (q *Queue) Compact() (freedSlots int) {
+	oldCap := len(q.buf)
+	if q.capacityFor(q.count) < oldCap {
+		q.resize(q.count)
+	}
+	return oldCap - len(q.buf)
+}
+
+// ShrinkTo shrinks the backing buffer to the capacity the queue would use to hold capacity
+// elements, or its current elements if there are more of them, so that a queue can be
+// brought back down to its steady-state size without having to grow again straight away.
+// It does nothing if the buffer is already that small.
+func (q *Queue) ShrinkTo(capacity int) {
+	if capacity < q.count {
+		capacity = q.count
+	}
+	if q.capacityFor(capacity) < len(q.buf) {
+		q.resize(capacity)
+	}
+}
+
+// scaleCapacity returns n multiplied by factor, capped at maxCapacity so that a large queue
+// or growth factor can't overflow an int.
+func scaleCapacity(n int, factor float64) int {
+	f := float64(n) * factor
+	if f >= float64(maxCapacity) {
+		return maxCapacity
+	}
+	return int(f)
+}
+
+// shrinkIfNeeded resizes the backing buffer down once the queue has drained to the shrink
+// ratio, leaving the same headroom a grow would.
+func (q *Queue) shrinkIfNeeded() {
+	if q.noShrink || len(q.buf) <= q.minLen || float64(q.count) > q.shrink*float64(len(q.buf)) {
+		q.below = 0
+		return
+	}
+	q.below++
+	if q.below < q.hysteresis {
+		return
+	}
+	if n := q.shrinkTarget(); n < len(q.buf) {
+		q.below = 0
+		q.resize(n)
+	}
+}
+
+// shrinkTarget returns the capacity shrinkIfNeeded resizes the backing buffer to.
+func (q *Queue) shrinkTarget() int {
+	return q.capacityFor(scaleCapacity(q.count, q.growth))
+}
+
+// NextShrinkCapacity returns the capacity the backing buffer would shrink to if the queue
+// checked whether to shrink at its current length, as it does after removing elements, or
+// the current capacity if it wouldn't shrink. It doesn't take SetShrinkHysteresis into
+// account, which only delays the shrink.
+func (q *Queue) NextShrinkCapacity() int {
+	if q.noShrink || len(q.buf) <= q.minLen || float64(q.count) > q.shrink*float64(len(q.buf)) {
+		return len(q.buf)
+	}
+	if n := q.shrinkTarget(); n < len(q.buf) {
+		return n
+	}
+	return len(q.buf)
+}
+
+// Add puts an element on the end of the queue. If the queue is a full ring, the element at
+// the head of the queue is removed first.
 func (q *Queue) Add(elem interface{}) {
+	q.checkElem(elem)
+	q.push(elem)
+}
+
+// push puts an element that has already been checked on the end of the queue.
+func (q *Queue) push(elem interface{}) {
+	if q.limit > 0 && q.count == q.limit {
+		q.evict(1)
+	}
 	if q.count == len(q.buf) {
-		q.resize()
+		q.grow()
 	}
 
 	q.buf[q.tail] = elem
-	q.tail = (q.tail + 1) % len(q.buf)
+	q.tail = q.wrap(q.tail + 1)
 	q.count++
+	q.added++
+	q.updateHighWaterMark()
+	q.mods++
+}
+
+// AddTracked puts an element on the end of the queue like Add, and reports whether doing so
+// resized the backing buffer. This helps attribute latency spikes to the adds that paid for
+// a copy.
+func (q *Queue) AddTracked(elem interface{}) (resized bool) {
+	resizes := q.resizes
+	q.Add(elem)
+	return q.resizes != resizes
+}
+
+// AddCapped puts an element on the end of the queue like Add and returns true, unless that
+// would need the backing buffer to grow past the maximum set by SetMaxCapacity, in which
+// case it returns false and leaves the queue untouched.
+func (q *Queue) AddCapped(elem interface{}) bool {
+	if q.maxCap > 0 && q.count == len(q.buf) && len(q.buf) >= q.maxCap {
+		return false
+	}
+	q.Add(elem)
+	return true
+}
+
+// AddIfRoom puts an element on the end of the queue only if that doesn't require growing
+// the backing buffer or, for a ring, evicting an element, and reports whether the element
+// was added. Combined with NewWithCapacity or NewRing, this rejects new elements once the
+// queue is full rather than making room for them.
+func (q *Queue) AddIfRoom(elem interface{}) bool {
+	if q.count == len(q.buf) || q.limit > 0 && q.count == q.limit {
+		return false
+	}
+	q.Add(elem)
+	return true
+}
+
+// AddAll puts the given elements on the end of the queue, in order, growing the backing
+// buffer at most once. The result is the same as calling Add for each element.
+func (q *Queue) AddAll(elems ...interface{}) {
+	if q.typ != nil || q.validate != nil {
+		for _, elem := range elems {
+			q.checkElem(elem)
+		}
+	}
+	if q.limit > 0 {
+		for _, elem := range elems {
+			q.push(elem)
+		}
+		return
+	}
+	q.Grow(len(elems))
+	q.copyIn(elems)
+}
+
+// AddAllIndexed puts the given elements on the end of the queue like AddAll, and returns
+// the index the first of them landed at; the others follow at consecutive indexes. Indexes
+// are relative to the head, so they only hold until elements are removed from the front.
+// If adding to a ring evicted some of the new elements themselves, the result is negative.
+func (q *Queue) AddAllIndexed(elems ...interface{}) (startIndex int) {
+	q.AddAll(elems...)
+	return q.count - len(elems)
+}
+
+// Fill puts n copies of elem on the end of the queue, growing the backing buffer at most
+// once. It panics if n is negative.
+func (q *Queue) Fill(n int, elem interface{}) {
+	if n < 0 {
+		panic("cannot fill a negative number of elements")
+	}
+	q.checkElem(elem)
+	if q.limit > 0 && n > q.limit {
+		n = q.limit
+	}
+	if q.limit > 0 && q.count+n > q.limit {
+		q.evict(q.count + n - q.limit)
+	}
+	q.Grow(n)
+	for i := 0; i < n; i++ {
+		q.buf[q.tail] = elem
+		q.tail = q.wrap(q.tail + 1)
+	}
+	q.count += n
+	q.added += uint64(n)
+	q.updateHighWaterMark()
+	q.mods++
+}
+
+// Grow makes sure the queue has room for n more elements without growing again, resizing
+// the backing buffer once if it does not. A ring never needs room for more than its
+// capacity, so it keeps the buffer it was constructed with. It panics if n is negative, or
+// if the queue would need a capacity larger than an int can hold.
+func (q *Queue) Grow(n int) {
+	if n < 0 {
+		panic("cannot grow by a negative number of elements")
+	}
+	if q.limit > 0 && n > q.limit-q.count {
+		n = q.limit - q.count
+	}
+	if n > maxCapacity-q.count {
+		panic("capacity too large")
+	}
+	if need := q.count + n; need > len(q.buf) {
+		// Above the threshold capacities aren't rounded up, so make sure a series of
+		// small batches still grows the buffer geometrically.
+		if q.threshold > 0 && len(q.buf) >= q.threshold {
+			if t := q.growTarget(); t > need {
+				need = t
+			}
+		}
+		q.resize(need)
+	}
+}
+
+// ReserveExact resizes the backing buffer to the smallest capacity that holds n elements,
+// or all of the current elements if there are more, shrinking it if it is larger. Unlike
+// Grow, which leaves any extra room in place, this leaves as little slack as possible for
+// callers that know the final size of the queue. Capacities are rounded up to a power of two
+// so that indexing can use a bitmask, so the result is only exact when n is a power of two,
+// or past the threshold set with SetGrowthThreshold, above which capacities aren't rounded;
+// it never drops below the minimum length of the queue either. A ring keeps the buffer it
+// was constructed with.
+func (q *Queue) ReserveExact(n int) {
+	if n < q.count {
+		n = q.count
+	}
+	if q.limit > 0 && n > q.limit {
+		n = q.limit
+	}
+	if q.capacityFor(n) != len(q.buf) {
+		q.resize(n)
+	}
+}
+
+// segments returns the elements of the queue as two slices of the backing buffer which,
+// taken in order, hold the elements from head to tail. The second is empty unless the
+// elements wrap around the end of the buffer.
+func (q *Queue) segments() (first, second []interface{}) {
+	if q.head+q.count <= len(q.buf) {
+		return q.buf[q.head : q.head+q.count], q.buf[:0]
+	}
+	return q.buf[q.head:], q.buf[:q.tail]
+}
+
+// PeekContiguous returns the longest run of elements from the head of the queue that is
+// contiguous in the backing buffer, without copying: either all of the elements, or those
+// up to the end of the buffer if they wrap around. After processing them, a caller can
+// RemoveN(len(s)) and call PeekContiguous again for the rest. The slice aliases the queue's
+// storage, so it must not be kept or relied on after the queue is modified.
+func (q *Queue) PeekContiguous() []interface{} {
+	first, _ := q.segments()
+	return first
+}
+
+// Segments returns the elements of the queue, without copying, as two slices which taken in
+// order hold them from head to tail: first runs from the head towards the end of the
+// backing buffer, and second, which is empty unless the elements wrap around the end of the
+// buffer, holds the rest from its start. Both slices alias the queue's storage, so they must
+// not be kept or relied on after the queue is modified.
+func (q *Queue) Segments() (first, second []interface{}) {
+	return q.segments()
+}
+
+// EnsureContiguous lays the elements of the queue out contiguously in the backing buffer if
+// they wrap around its end, and returns them as a single slice, in order, for code that
+// wants one slice, such as a single copy into a network buffer. The elements are rotated in
+// place, so this doesn't allocate. When the elements are already contiguous it only returns
+// them. As with PeekContiguous, the slice aliases the queue's storage and is only valid
+// until the next call that modifies the queue.
+func (q *Queue) EnsureContiguous() []interface{} {
+	if q.IsWrapped() {
+		q.Normalize()
+	}
+	return q.buf[q.head : q.head+q.count]
+}
+
+// Normalize rotates the backing buffer in place so that the head of the queue is at its
+// start, which leaves the elements at indexes 0 to Length()-1 of the buffer, in order. It
+// doesn't allocate, and does nothing if the head is already at the start. EnsureContiguous
+// does less work when all that is needed is a single slice of the elements.
+func (q *Queue) Normalize() {
+	if q.head == 0 {
+		return
+	}
+	reverse(q.buf[:q.head])
+	reverse(q.buf[q.head:])
+	reverse(q.buf)
+	q.head = 0
+	q.tail = q.wrap(q.count)
+	q.mods++
+}
+
+// reverse reverses the order of the elements of s in place.
+func reverse(s []interface{}) {
+	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+		s[i], s[j] = s[j], s[i]
+	}
+}
+
+// WriteContiguous makes sure the queue has room for n more elements, like Grow, and returns
+// the free slots of the backing buffer that follow the tail contiguously, for the caller to
+// write elements into directly. The slice may be shorter than n when the free space wraps
+// around the end of the buffer; Commit what was written and call WriteContiguous again for
+// the rest. The slice aliases the queue's storage and is only valid until the next call
+// that modifies the queue. A ring doesn't grow: the slice never holds more slots than are
+// left before its capacity, and is empty once it is full.
+func (q *Queue) WriteContiguous(n int) []interface{} {
+	if q.limit > 0 && n > q.limit-q.count {
+		n = q.limit - q.count
+	}
+	q.Grow(n)
+	if q.count == len(q.buf) {
+		return q.buf[:0]
+	}
+	s := q.buf[q.tail:]
+	if q.tail < q.head {
+		s = q.buf[q.tail:q.head]
+	}
+	if q.limit > 0 && len(s) > q.limit-q.count {
+		s = s[:q.limit-q.count]
+	}
+	return s
+}
+
+// Commit adds to the end of the queue the n elements written into the slice returned by the
+// last call to WriteContiguous. It panics if n is negative or more than the queue has room
+// for, which for a ring is what is left before its capacity.
+func (q *Queue) Commit(n int) {
+	room := len(q.buf) - q.count
+	if q.limit > 0 {
+		room = q.limit - q.count
+	}
+	if n < 0 || n > room {
+		panic("index out of range")
+	}
+	for i := 0; i < n && (q.typ != nil || q.validate != nil); i++ {
+		q.checkElem(q.buf[q.wrap(q.tail+i)])
+	}
+	q.tail = q.wrap(q.tail + n)
+	q.count += n
+	q.added += uint64(n)
+	q.updateHighWaterMark()
+	q.mods++
+}
+
+// copyIn puts elems on the end of the queue, which must already have room for them.
+func (q *Queue) copyIn(elems []interface{}) {
+	n := copy(q.buf[q.tail:], elems)
+	copy(q.buf, elems[n:])
+	q.tail = q.wrap(q.tail + len(elems))
+	q.count += len(elems)
+	q.added += uint64(len(elems))
+	q.updateHighWaterMark()
+	q.mods++
+}
+
+// AddFront puts an element on the front of the queue, so that it is the next element
+// returned by Peek. If the queue is a full ring, the element at the back of the queue is
+// removed first.
+func (q *Queue) AddFront(elem interface{}) {
+	q.checkElem(elem)
+	q.pushFront(elem)
+}
+
+// pushFront puts an element that has already been checked on the front of the queue.
+func (q *Queue) pushFront(elem interface{}) {
+	if q.limit > 0 && q.count == q.limit {
+		if q.onEvict != nil {
+			q.onEvict(q.PeekBack())
+		}
+		q.discardBack(1)
+	}
+	if q.count == len(q.buf) {
+		q.grow()
+	}
+
+	q.head = q.wrap(q.head - 1)
+	q.buf[q.head] = elem
+	q.count++
+	q.added++
+	q.updateHighWaterMark()
+	q.mods++
+}
+
+// AddFrontAll puts the given elements on the front of the queue, in order, growing the
+// backing buffer at most once, so that the first of them is the next element returned by
+// Peek and the old elements follow the last of them. If the queue is a ring, elements at the
+// back are removed to make room, as for AddFront.
+func (q *Queue) AddFrontAll(elems ...interface{}) {
+	if q.typ != nil || q.validate != nil {
+		for _, elem := range elems {
+			q.checkElem(elem)
+		}
+	}
+	if q.limit > 0 {
+		for i := len(elems) - 1; i >= 0; i-- {
+			q.pushFront(elems[i])
+		}
+		return
+	}
+	q.Grow(len(elems))
+	q.head = q.wrap(q.head - len(elems))
+	n := copy(q.buf[q.head:], elems)
+	copy(q.buf, elems[n:])
+	q.count += len(elems)
+	q.added += uint64(len(elems))
+	q.updateHighWaterMark()
+	q.mods++
+}
+
+// Insert puts an element into the queue at index i, before the element currently at that
+// index, so Insert(0, elem) is equivalent to AddFront and Insert(Length(), elem) to Add.
+// Whichever of the elements before or after i are fewer get shifted to open a gap. If the
+// queue is a full ring, the element at the head is removed first, except that Insert(0,
+// elem) removes the one at the back, as AddFront does. If the index is invalid, the call
+// will panic.
+func (q *Queue) Insert(i int, elem interface{}) {
+	if i > q.Length() || i < 0 {
+		panic("index out of range")
+	}
+	if i == 0 {
+		q.AddFront(elem)
+		return
+	}
+	q.checkElem(elem)
+	if q.limit > 0 && q.count == q.limit {
+		q.evict(1)
+		i--
+	}
+	if q.count == len(q.buf) {
+		q.grow()
+	}
+
+	if i < q.count/2 {
+		q.head = q.wrap(q.head - 1)
+		for j := 0; j < i; j++ {
+			q.buf[q.wrap(q.head+j)] = q.buf[q.wrap(q.head+j+1)]
+		}
+	} else {
+		for j := q.count; j > i; j-- {
+			q.buf[q.wrap(q.head+j)] = q.buf[q.wrap(q.head+j-1)]
+		}
+		q.tail = q.wrap(q.tail + 1)
+	}
+	q.buf[q.wrap(q.head+i)] = elem
+	q.count++
+	q.added++
+	q.updateHighWaterMark()
+	q.mods++
 }
 
 // Peek returns the element at the head of the queue. If the queue is empty (Length == 0),
@@ -59,24 +949,1039 @@ func (q *Queue) Peek() interface{} {
 	return q.buf[q.head]
 }
 
+// TryPeek returns the element at the head of the queue and true, or nil and false if the
+// queue is empty.
+func (q *Queue) TryPeek() (interface{}, bool) {
+	if q.count == 0 {
+		return nil, false
+	}
+	return q.buf[q.head], true
+}
+
+// PeekWithLen returns the element at the head of the queue along with the length of the
+// queue, and true, or nil, 0 and false if the queue is empty.
+func (q *Queue) PeekWithLen() (elem interface{}, remaining int, ok bool) {
+	if q.count == 0 {
+		return nil, 0, false
+	}
+	return q.buf[q.head], q.count, true
+}
+
+// PeekBack returns the element at the back of the queue, that is the most recently added
+// one. Like Peek, it returns garbage if the queue is empty.
+func (q *Queue) PeekBack() interface{} {
+	return q.buf[q.wrap(q.tail-1)]
+}
+
+// TryPeekBack returns the element at the back of the queue and true, or nil and false if
+// the queue is empty.
+func (q *Queue) TryPeekBack() (interface{}, bool) {
+	if q.count == 0 {
+		return nil, false
+	}
+	return q.PeekBack(), true
+}
+
+// PeekBackN returns the element n positions before the back of the queue and true, so that
+// PeekBackN(0) is the most recently added element, or nil and false if the queue holds n
+// elements or fewer. A negative n also returns nil and false.
+func (q *Queue) PeekBackN(n int) (interface{}, bool) {
+	if n >= q.count || n < 0 {
+		return nil, false
+	}
+	return q.buf[q.wrap(q.tail-1-n)], true
+}
+
 // Get returns the element at index i in the queue. If the index is invalid, the
 // call will panic.
 func (q *Queue) Get(i int) interface{} {
 	if i >= q.Length() || i < 0 {
 		panic("index out of range")
 	}
-	modi := (q.head + i) % len(q.buf)
+	modi := q.wrap(q.head + i)
 	return q.buf[modi]
 }
 
+// GetMany returns a new slice holding the elements at the given indexes in the queue, in
+// the order the indexes are given. If any index is invalid, the call will panic.
+func (q *Queue) GetMany(indices ...int) []interface{} {
+	s := make([]interface{}, len(indices))
+	for n, i := range indices {
+		if i >= q.count || i < 0 {
+			panic("index out of range")
+		}
+		s[n] = q.buf[q.wrap(q.head+i)]
+	}
+	return s
+}
+
+// GetOK returns the element at index i in the queue and true, or nil and false if the index
+// is invalid.
+func (q *Queue) GetOK(i int) (interface{}, bool) {
+	if i >= q.count || i < 0 {
+		return nil, false
+	}
+	return q.buf[q.wrap(q.head+i)], true
+}
+
+// GetRange returns a new slice holding the elements from index start up to, but not
+// including, index end, in order. If the range is invalid, the call will panic.
+func (q *Queue) GetRange(start, end int) []interface{} {
+	if start < 0 || end > q.Length() || start > end {
+		panic("index out of range")
+	}
+	s := make([]interface{}, end-start)
+	modstart := q.wrap(q.head + start)
+	n := copy(s, q.buf[modstart:])
+	copy(s[n:], q.buf)
+	return s
+}
+
+// Set replaces the element at index i in the queue. If the index is invalid, the call will
+// panic.
+func (q *Queue) Set(i int, elem interface{}) {
+	if i >= q.Length() || i < 0 {
+		panic("index out of range")
+	}
+	q.checkElem(elem)
+	modi := q.wrap(q.head + i)
+	q.buf[modi] = elem
+	q.mods++
+}
+
+// Replace replaces the element at index i in the queue, like Set, and returns the element
+// that was there. If the index is invalid, the call will panic.
+func (q *Queue) Replace(i int, elem interface{}) interface{} {
+	if i >= q.Length() || i < 0 {
+		panic("index out of range")
+	}
+	q.checkElem(elem)
+	modi := q.wrap(q.head + i)
+	old := q.buf[modi]
+	q.buf[modi] = elem
+	q.mods++
+	return old
+}
+
 // Remove removes the element from the front of the queue. If you actually want the element,
 // call Peek first. If the queue is empty (Length == 0), Remove will put the queue in a bad
 // state and all further operations will be undefined.
 func (q *Queue) Remove() {
-	q.buf[q.head] = nil
-	q.head = (q.head + 1) % len(q.buf)
+	if q.onRemove != nil {
+		q.onRemove(q.buf[q.head])
+	}
+	q.remove()
+}
+
+// remove removes the element from the front of the queue without passing it to onRemove.
+func (q *Queue) remove() {
+	if !q.noZero {
+		q.buf[q.head] = nil
+	}
+	q.head = q.wrap(q.head + 1)
 	q.count--
-	if len(q.buf) > minQueueLen && q.count*4 <= len(q.buf) {
-		q.resize()
+	q.removed++
+	q.popped++
+	q.mods++
+	q.shrinkIfNeeded()
+}
+
+// RemoveN removes n elements from the front of the queue, shrinking it at most once. It
+// panics if n is negative or greater than the length of the queue.
+func (q *Queue) RemoveN(n int) {
+	if n > q.count || n < 0 {
+		panic("index out of range")
+	}
+	q.release(0, n)
+	q.discard(n)
+	q.shrinkIfNeeded()
+}
+
+// Truncate removes elements from the back of the queue until it holds at most n, keeping
+// the n oldest, and shrinks it at most once. It does nothing if the queue already holds n
+// elements or fewer, and panics if n is negative.
+func (q *Queue) Truncate(n int) {
+	if n < 0 {
+		panic("index out of range")
+	}
+	if n < q.count {
+		q.release(n, q.count-n)
+		q.discardBack(q.count - n)
+		q.shrinkIfNeeded()
+	}
+}
+
+// TruncateFront removes elements from the front of the queue until it holds at most n,
+// keeping the n newest, and shrinks it at most once. It does nothing if the queue already
+// holds n elements or fewer, and panics if n is negative.
+func (q *Queue) TruncateFront(n int) {
+	if n < 0 {
+		panic("index out of range")
+	}
+	if n < q.count {
+		q.release(0, q.count-n)
+		q.discard(q.count - n)
+		q.shrinkIfNeeded()
+	}
+}
+
+// RemoveUntil removes elements from the front of the queue up to and including the first
+// one for which match returns true, shrinking the queue at most once, and returns how many
+// were removed. If no element matches, nothing is removed and it returns 0.
+func (q *Queue) RemoveUntil(match func(elem interface{}) bool) int {
+	for i := 0; i < q.count; i++ {
+		if match(q.buf[q.wrap(q.head+i)]) {
+			q.release(0, i+1)
+			q.discard(i + 1)
+			q.shrinkIfNeeded()
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Swap exchanges the elements at indexes i and j in the queue. If either index is invalid,
+// the call will panic.
+func (q *Queue) Swap(i, j int) {
+	if i >= q.Length() || i < 0 || j >= q.Length() || j < 0 {
+		panic("index out of range")
+	}
+	modi := q.wrap(q.head + i)
+	modj := q.wrap(q.head + j)
+	q.buf[modi], q.buf[modj] = q.buf[modj], q.buf[modi]
+	q.mods++
+}
+
+// RemoveAt removes the element at index i in the queue, keeping the remaining elements in
+// order. Whichever of the elements before or after i are fewer get shifted to close the gap.
+// If the index is invalid, the call will panic.
+func (q *Queue) RemoveAt(i int) {
+	if i >= q.Length() || i < 0 {
+		panic("index out of range")
+	}
+	q.release(i, 1)
+	q.removeAt(i)
+}
+
+// removeAt removes the element at index i like RemoveAt, without passing it to onRemove.
+func (q *Queue) removeAt(i int) {
+	if i < q.count/2 {
+		for j := i; j > 0; j-- {
+			q.buf[q.wrap(q.head+j)] = q.buf[q.wrap(q.head+j-1)]
+		}
+		if !q.noZero {
+			q.buf[q.head] = nil
+		}
+		q.head = q.wrap(q.head + 1)
+	} else {
+		for j := i; j < q.count-1; j++ {
+			q.buf[q.wrap(q.head+j)] = q.buf[q.wrap(q.head+j+1)]
+		}
+		q.tail = q.wrap(q.tail - 1)
+		if !q.noZero {
+			q.buf[q.tail] = nil
+		}
+	}
+	q.count--
+	q.removed++
+	q.mods++
+	q.shrinkIfNeeded()
+}
+
+// RemoveFirst removes the first element of the queue, starting from the head, for which
+// match returns true, and returns it and true, or returns nil and false if there is no such
+// element. The element is removed as by RemoveAt.
+func (q *Queue) RemoveFirst(match func(elem interface{}) bool) (interface{}, bool) {
+	for i := 0; i < q.count; i++ {
+		if elem := q.buf[q.wrap(q.head+i)]; match(elem) {
+			q.removeAt(i)
+			return elem, true
+		}
+	}
+	return nil, false
+}
+
+// RemoveIf removes the element at the front of the queue and returns it and true if pred
+// returns true for it. Otherwise, or if the queue is empty, it leaves the queue unchanged
+// and returns nil and false.
+func (q *Queue) RemoveIf(pred func(head interface{}) bool) (interface{}, bool) {
+	if q.count == 0 || !pred(q.buf[q.head]) {
+		return nil, false
+	}
+	return q.Pop(), true
+}
+
+// Pop removes the element from the front of the queue and returns it. Like Remove, calling
+// Pop on an empty queue puts the queue in a bad state; use PopOK if you don't know whether
+// the queue has elements.
+func (q *Queue) Pop() interface{} {
+	elem := q.buf[q.head]
+	q.remove()
+	return elem
+}
+
+// PopOK removes and returns the element from the front of the queue. If the queue is empty
+// it returns nil and false and leaves the queue untouched.
+func (q *Queue) PopOK() (interface{}, bool) {
+	if q.count == 0 {
+		return nil, false
+	}
+	return q.Pop(), true
+}
+
+// RemoveBack removes the element from the back of the queue, that is the most recently
+// added one. If the queue is empty (Length == 0), RemoveBack will put the queue in a bad
+// state and all further operations will be undefined.
+func (q *Queue) RemoveBack() {
+	q.release(q.count-1, 1)
+	q.tail = q.wrap(q.tail - 1)
+	if !q.noZero {
+		q.buf[q.tail] = nil
+	}
+	q.count--
+	q.removed++
+	q.mods++
+	q.shrinkIfNeeded()
+}
+
+// RemoveBackN removes n elements from the back of the queue, shrinking it at most once. It
+// panics if n is negative or greater than the length of the queue.
+func (q *Queue) RemoveBackN(n int) {
+	if n > q.count || n < 0 {
+		panic("index out of range")
+	}
+	q.release(q.count-n, n)
+	q.discardBack(n)
+	q.shrinkIfNeeded()
+}
+
+// Clear removes all elements from the queue, keeping the backing buffer so that refilling
+// the queue does not have to grow it again.
+func (q *Queue) Clear() {
+	q.release(0, q.count)
+	q.discard(q.count)
+	q.head = 0
+	q.tail = 0
+	q.count = 0
+	q.mods++
+}
+
+// Reset removes all elements from the queue and shrinks the backing buffer to the minimum
+// length of the queue, or to the capacity of a ring, releasing any memory the queue has
+// grown into. That can be less than it was constructed with: a queue from NewWithCapacity
+// is left with the default minimum of 16. The queue keeps its configuration and is safe to
+// reuse afterwards, which makes Reset the right call before putting a queue back in a
+// sync.Pool: the pooled queue neither keeps its old elements alive nor pins a large buffer.
+func (q *Queue) Reset() {
+	n := q.capacityFor(q.limit)
+	if len(q.buf) == n {
+		q.Clear()
+		return
+	}
+	q.release(0, q.count)
+	q.removed += uint64(q.count)
+	q.freeBuf(q.buf)
+	q.setBuf(q.allocBuf(n))
+	q.head = 0
+	q.tail = 0
+	q.count = 0
+	q.mods++
+	q.resizes++
+}
+
+// Verify panics if any slot of the backing buffer outside the live range of the queue still
+// holds an element, which means some method kept a reference to an element it removed. It
+// is meant for tests and debugging, since it checks the whole buffer. It does nothing for a
+// queue that doesn't zero removed slots, see SetZeroOnRemove.
+func (q *Queue) Verify() {
+	if q.noZero {
+		return
+	}
+	for i := q.count; i < len(q.buf); i++ {
+		modi := q.wrap(q.head + i)
+		if q.buf[modi] != nil {
+			panic(fmt.Sprintf("slot %d outside the live range holds %v (head %d, count %d, capacity %d)",
+				modi, q.buf[modi], q.head, q.count, len(q.buf)))
+		}
+	}
+}
+
+// CheckInvariants returns an error describing the first inconsistency it finds in the
+// bookkeeping of the queue: a backing buffer whose length isn't a power of two when it
+// should be, a head or tail outside the buffer, a count that doesn't fit in it or doesn't
+// match the distance from head to tail, or a ring holding more than its capacity. It
+// returns nil for a healthy queue, including a zero Queue that has no buffer yet. Like
+// Verify, it is meant for tests and debugging, but it only takes constant time.
+func (q *Queue) CheckInvariants() error {
+	n := len(q.buf)
+	switch {
+	case n == 0 && q.count == 0 && q.head == 0 && q.tail == 0:
+		return nil
+	case n == 0 || !q.allowsCap(n):
+		return fmt.Errorf("queue: capacity %d is not a power of two", n)
+	case q.mask != n-1 && (q.mask != -1 || n&(n-1) == 0):
+		return fmt.Errorf("queue: capacity %d has the wrong indexing mode", n)
+	case q.count < 0 || q.count > n:
+		return fmt.Errorf("queue: count %d is outside [0, %d]", q.count, n)
+	case q.head < 0 || q.head >= n:
+		return fmt.Errorf("queue: head %d is outside [0, %d)", q.head, n)
+	case q.tail < 0 || q.tail >= n:
+		return fmt.Errorf("queue: tail %d is outside [0, %d)", q.tail, n)
+	case q.wrap(q.head+q.count) != q.tail:
+		return fmt.Errorf("queue: head %d and tail %d don't span count %d (capacity %d)", q.head, q.tail, q.count, n)
+	case q.limit > 0 && q.count > q.limit:
+		return fmt.Errorf("queue: count %d is over the ring capacity %d", q.count, q.limit)
+	}
+	return nil
+}
+
+// ToSlice returns a new slice holding the elements of the queue in order, from head to
+// tail. The queue itself is left unchanged.
+func (q *Queue) ToSlice() []interface{} {
+	s := make([]interface{}, q.count)
+	q.copyOut(s)
+	return s
+}
+
+// String returns a representation of the elements of the queue in order, from head to
+// tail, in the form "Queue[a b c]".
+func (q *Queue) String() string {
+	return fmt.Sprintf("Queue%v", q.ToSlice())
+}
+
+// Dump returns a representation of the backing buffer of the queue as it is laid out in
+// memory, for debugging, in the form "Queue(count 3)[_ H:a b c T:_]": empty slots are shown
+// as _, and the slots at the head and tail are marked with H: and T:. Unlike String, it
+// doesn't rely on the bookkeeping of the queue being consistent, so it can be called on a
+// corrupt queue.
+func (q *Queue) Dump() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Queue(count %d)[", q.count)
+	for i, elem := range q.buf {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		if i == q.head {
+			b.WriteString("H:")
+		}
+		if i == q.tail {
+			b.WriteString("T:")
+		}
+		if elem == nil {
+			b.WriteByte('_')
+		} else {
+			fmt.Fprint(&b, elem)
+		}
+	}
+	b.WriteByte(']')
+	return b.String()
+}
+
+// Clone returns a copy of the queue with its own backing buffer, so that adding to or
+// removing from one queue does not affect the other. The elements themselves are not
+// copied; both queues refer to the same values.
+func (q *Queue) Clone() *Queue {
+	c := *q
+	c.buf = q.allocBuf(len(q.buf))
+	copy(c.buf, q.buf)
+	return &c
+}
+
+// CloneWithCapacity returns a copy of the queue like Clone, but whose backing buffer is
+// sized to hold capacity elements, or all the elements of the queue if there are more of
+// them, rather than matching the buffer of q. This trims the slack from a snapshot that is
+// kept for a long time, or makes room up front in a copy that will grow. The elements start
+// at the front of the new buffer.
+func (q *Queue) CloneWithCapacity(capacity int) *Queue {
+	if capacity < q.count {
+		capacity = q.count
+	}
+	c := *q
+	c.setBuf(q.allocBuf(q.capacityFor(capacity)))
+	q.copyOut(c.buf)
+	c.head = 0
+	c.tail = c.wrap(q.count)
+	return &c
+}
+
+// SwapWith exchanges the elements of the queue with those of other in O(1), by swapping
+// their backing buffers rather than copying, for double buffering between a producer and a
+// consumer. Each queue keeps its own configuration. Like the rest of the package, it is not
+// safe for concurrent use. It panics if either queue is a ring that can't hold the elements
+// of the other, if either backing buffer is larger than the other queue's maximum capacity
+// or has grown past a growth threshold the other queue doesn't share, if either queue
+// doesn't accept the elements of the other, as checked by NewTyped and SetValidator, or if
+// the queues have different allocators, since each buffer must go back to the Allocator it
+// came from.
+func (q *Queue) SwapWith(other *Queue) {
+	if q.limit > 0 && other.count > q.limit || other.limit > 0 && q.count > other.limit {
+		panic("ring capacity exceeded")
+	}
+	if q.allocator != other.allocator {
+		panic("cannot swap buffers between queues with different allocators")
+	}
+	if q.maxCap > 0 && len(other.buf) > q.maxCap || other.maxCap > 0 && len(q.buf) > other.maxCap {
+		panic("queue is at its maximum capacity")
+	}
+	if !q.allowsCap(len(other.buf)) || !other.allowsCap(len(q.buf)) {
+		panic("cannot swap a buffer past the growth threshold into a queue without it")
+	}
+	q.checkAll(other)
+	other.checkAll(q)
+	q.buf, other.buf = other.buf, q.buf
+	q.mask, other.mask = other.mask, q.mask
+	q.head, other.head = other.head, q.head
+	q.tail, other.tail = other.tail, q.tail
+	q.count, other.count = other.count, q.count
+	q.mods++
+	other.mods++
+	q.updateHighWaterMark()
+	other.updateHighWaterMark()
+}
+
+// checkAll runs checkElem on every element of other, if q checks its elements at all.
+func (q *Queue) checkAll(other *Queue) {
+	for i := 0; i < other.count && (q.typ != nil || q.validate != nil); i++ {
+		q.checkElem(other.buf[other.wrap(other.head+i)])
+	}
+}
+
+// CopyTo replaces the contents of dst with the elements of the queue, in order, reusing
+// dst's backing buffer and only growing it if it is too small. It is the allocation-free
+// counterpart to Clone for when a queue is overwritten over and over; dst keeps its own
+// configuration.
+func (q *Queue) CopyTo(dst *Queue) {
+	if dst == q {
+		return
+	}
+	dst.Clear()
+	dst.Merge(q)
+}
+
+// PeekInto copies up to len(dst) elements from the front of the queue into dst, in order,
+// without removing them, and returns the number of elements copied. Unlike PeekN, it
+// doesn't allocate, so a caller polling the queue can reuse dst across calls.
+func (q *Queue) PeekInto(dst []interface{}) int {
+	return q.copyOut(dst)
+}
+
+// DrainTo removes up to len(dst) elements from the front of the queue, copying them into dst
+// in order, and returns the number of elements moved. The queue is shrunk at most once,
+// after all the elements have been removed.
+func (q *Queue) DrainTo(dst []interface{}) int {
+	n := q.copyOut(dst)
+	q.discard(n)
+	q.shrinkIfNeeded()
+	return n
+}
+
+// DrainFunc passes each element of the queue to fn in order, from head to tail, removing
+// it once fn returns nil. If fn returns an error, DrainFunc stops and returns it, leaving
+// the element that failed at the front of the queue followed by the ones not yet drained,
+// so the drain can be retried from where it stopped. The queue is shrunk at most once. fn
+// must not modify the queue.
+func (q *Queue) DrainFunc(fn func(elem interface{}) error) error {
+	defer q.shrinkIfNeeded()
+	for q.count > 0 {
+		if err := fn(q.buf[q.head]); err != nil {
+			return err
+		}
+		q.discard(1)
+	}
+	return nil
+}
+
+// evict removes n elements from the front of a ring to make room for new ones, passing each
+// to onEvict.
+func (q *Queue) evict(n int) {
+	for i := 0; i < n && q.onEvict != nil; i++ {
+		q.onEvict(q.buf[q.wrap(q.head+i)])
+	}
+	q.discard(n)
+}
+
+// release passes the n elements starting at index i to onRemove, if it is set, before they
+// are discarded.
+func (q *Queue) release(i, n int) {
+	for j := i; j < i+n && q.onRemove != nil; j++ {
+		q.onRemove(q.buf[q.wrap(q.head+j)])
+	}
+}
+
+// discard removes n elements from the front of the queue, without shrinking it.
+func (q *Queue) discard(n int) {
+	for i := 0; i < n && !q.noZero; i++ {
+		q.buf[q.wrap(q.head+i)] = nil
+	}
+	q.head = q.wrap(q.head + n)
+	q.count -= n
+	q.removed += uint64(n)
+	q.popped += uint64(n)
+	q.mods++
+}
+
+// IndexOf returns the index of the first element of the queue, starting from the head, for
+// which eq(elem, element) returns true, or -1 if there is no such element.
+func (q *Queue) IndexOf(elem interface{}, eq func(a, b interface{}) bool) int {
+	for i := 0; i < q.count; i++ {
+		if eq(elem, q.buf[q.wrap(q.head+i)]) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Contains reports whether the queue holds an element for which eq(elem, element) returns
+// true.
+func (q *Queue) Contains(elem interface{}, eq func(a, b interface{}) bool) bool {
+	return q.IndexOf(elem, eq) >= 0
+}
+
+// CountIf returns the number of elements of the queue for which pred returns true.
+func (q *Queue) CountIf(pred func(elem interface{}) bool) int {
+	n := 0
+	for i := 0; i < q.count; i++ {
+		if pred(q.buf[q.wrap(q.head+i)]) {
+			n++
+		}
+	}
+	return n
+}
+
+// Min returns the smallest element of the queue according to less and true, or nil and
+// false if the queue is empty. If several elements are equally small, the first one wins.
+func (q *Queue) Min(less func(a, b interface{}) bool) (interface{}, bool) {
+	if q.count == 0 {
+		return nil, false
+	}
+	min := q.buf[q.head]
+	for i := 1; i < q.count; i++ {
+		if elem := q.buf[q.wrap(q.head+i)]; less(elem, min) {
+			min = elem
+		}
+	}
+	return min, true
+}
+
+// Max returns the largest element of the queue according to less and true, or nil and
+// false if the queue is empty. If several elements are equally large, the first one wins.
+func (q *Queue) Max(less func(a, b interface{}) bool) (interface{}, bool) {
+	if q.count == 0 {
+		return nil, false
+	}
+	max := q.buf[q.head]
+	for i := 1; i < q.count; i++ {
+		if elem := q.buf[q.wrap(q.head+i)]; less(max, elem) {
+			max = elem
+		}
+	}
+	return max, true
+}
+
+// Equal reports whether the queue and other hold the same number of elements, and eq returns
+// true for each pair of elements at the same index. Only the order of elements matters, not
+// where they happen to sit in the backing buffers.
+func (q *Queue) Equal(other *Queue, eq func(a, b interface{}) bool) bool {
+	if q.count != other.count {
+		return false
+	}
+	for i := 0; i < q.count; i++ {
+		if !eq(q.buf[q.wrap(q.head+i)], other.buf[other.wrap(other.head+i)]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Compare compares the queue and other lexicographically, using cmp to compare the elements
+// at each index in order, and returns -1, 0 or +1 as the queue sorts before, the same as or
+// after other. cmp must return a negative number, zero or a positive number as its first
+// argument is less than, equal to or greater than its second, and if one queue is a prefix
+// of the other, the shorter one sorts first.
+func (q *Queue) Compare(other *Queue, cmp func(a, b interface{}) int) int {
+	for i := 0; i < q.count && i < other.count; i++ {
+		c := cmp(q.buf[q.wrap(q.head+i)], other.buf[other.wrap(other.head+i)])
+		switch {
+		case c < 0:
+			return -1
+		case c > 0:
+			return +1
+		}
+	}
+	switch {
+	case q.count < other.count:
+		return -1
+	case q.count > other.count:
+		return +1
+	}
+	return 0
+}
+
+// EqualUnordered reports whether the queue and other hold the same elements regardless of
+// order: they must have the same length, and each element of the queue must pair up with a
+// different element of other for which eq returns true. It takes O(n²) calls to eq, but
+// works for elements of any type.
+func (q *Queue) EqualUnordered(other *Queue, eq func(a, b interface{}) bool) bool {
+	if q.count != other.count {
+		return false
+	}
+	paired := make([]bool, other.count)
+	for i := 0; i < q.count; i++ {
+		elem := q.buf[q.wrap(q.head+i)]
+		found := false
+		for j := 0; j < other.count && !found; j++ {
+			if !paired[j] && eq(elem, other.buf[other.wrap(other.head+j)]) {
+				paired[j] = true
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// Hash returns a 64-bit digest of the elements of the queue, in order, computed by feeding
+// the result of h for each element through FNV-1a. Queues holding equal elements in the
+// same order hash the same however their elements are laid out in memory, so comparing
+// hashes is a cheap first check that two queues match; different orders almost always hash
+// differently.
+func (q *Queue) Hash(h func(elem interface{}) uint64) uint64 {
+	const (
+		offset64 = 14695981039346656037
+		prime64  = 1099511628211
+	)
+	sum := uint64(offset64)
+	for i := 0; i < q.count; i++ {
+		v := h(q.buf[q.wrap(q.head+i)])
+		for b := 0; b < 64; b += 8 {
+			sum ^= v >> b & 0xff
+			sum *= prime64
+		}
+	}
+	return sum
+}
+
+// Sample returns k elements of the queue chosen uniformly at random using rng, in no
+// particular order, or all of them if the queue holds fewer than k. The queue is left
+// unchanged. It panics if k is negative.
+func (q *Queue) Sample(k int, rng *rand.Rand) []interface{} {
+	if k < 0 {
+		panic("sample size must not be negative")
+	}
+	if k > q.count {
+		k = q.count
+	}
+	s := make([]interface{}, k)
+	q.copyOut(s)
+	for i := k; i < q.count; i++ {
+		if j := rng.Intn(i + 1); j < k {
+			s[j] = q.buf[q.wrap(q.head+i)]
+		}
+	}
+	return s
+}
+
+// ForEach calls fn for each element of the queue in order, from head to tail, along with
+// its index. It stops early if fn returns false. The queue must not be modified by fn.
+func (q *Queue) ForEach(fn func(i int, elem interface{}) bool) {
+	for i := 0; i < q.count; i++ {
+		if !fn(i, q.buf[q.wrap(q.head+i)]) {
+			return
+		}
+	}
+}
+
+// Rotate moves the first n elements of the queue to the back, in order, so the element at
+// index n becomes the head. A negative n moves the last -n elements to the front instead. n
+// is taken modulo the length of the queue. When the queue fills its backing buffer this only
+// adjusts the head and tail, otherwise whichever side is shorter is moved.
+func (q *Queue) Rotate(n int) {
+	if q.count == 0 {
+		return
+	}
+	n %= q.count
+	if n < 0 {
+		n += q.count
+	}
+	q.mods++
+	if q.count == len(q.buf) {
+		q.head = q.wrap(q.head + n)
+		q.tail = q.head
+		return
+	}
+	if n <= q.count/2 {
+		for ; n > 0; n-- {
+			q.buf[q.tail] = q.buf[q.head]
+			q.buf[q.head] = nil
+			q.head = q.wrap(q.head + 1)
+			q.tail = q.wrap(q.tail + 1)
+		}
+	} else {
+		for n = q.count - n; n > 0; n-- {
+			q.head = q.wrap(q.head - 1)
+			q.tail = q.wrap(q.tail - 1)
+			q.buf[q.head] = q.buf[q.tail]
+			q.buf[q.tail] = nil
+		}
+	}
+}
+
+// Reverse reverses the order of the elements of the queue in place.
+func (q *Queue) Reverse() {
+	for i, j := 0, q.count-1; i < j; i, j = i+1, j-1 {
+		modi, modj := q.wrap(q.head+i), q.wrap(q.head+j)
+		q.buf[modi], q.buf[modj] = q.buf[modj], q.buf[modi]
+	}
+	q.mods++
+}
+
+// discardBack removes n elements from the back of the queue, without shrinking it.
+func (q *Queue) discardBack(n int) {
+	for i := q.count - n; i < q.count && !q.noZero; i++ {
+		q.buf[q.wrap(q.head+i)] = nil
+	}
+	q.tail = q.wrap(q.tail - n)
+	q.count -= n
+	q.removed += uint64(n)
+	q.mods++
+}
+
+// Filter removes every element for which keep returns false, in a single pass, leaving the
+// remaining elements in order. The queue is shrunk at most once, at the end.
+func (q *Queue) Filter(keep func(elem interface{}) bool) {
+	kept := 0
+	for i := 0; i < q.count; i++ {
+		elem := q.buf[q.wrap(q.head+i)]
+		if keep(elem) {
+			q.buf[q.wrap(q.head+kept)] = elem
+			kept++
+		} else if q.onRemove != nil {
+			q.onRemove(elem)
+		}
+	}
+	q.discardBack(q.count - kept)
+	q.shrinkIfNeeded()
+}
+
+// ProcessAndRemove calls fn for each element of the queue in order, from head to tail, and
+// removes the elements for which it returns true, in a single pass. The remaining elements
+// keep their order, and the queue is shrunk at most once. fn must not modify the queue.
+func (q *Queue) ProcessAndRemove(fn func(elem interface{}) (remove bool)) {
+	kept := 0
+	for i := 0; i < q.count; i++ {
+		elem := q.buf[q.wrap(q.head+i)]
+		if !fn(elem) {
+			q.buf[q.wrap(q.head+kept)] = elem
+			kept++
+		} else if q.onRemove != nil {
+			q.onRemove(elem)
+		}
+	}
+	q.discardBack(q.count - kept)
+	q.shrinkIfNeeded()
+}
+
+// Distinct removes every element of the queue for which eq returns true when compared to an
+// element nearer the head, so that only the first occurrence of each element is kept. The
+// remaining elements keep their order, and the queue is shrunk at most once. It takes
+// O(n²) calls to eq, but works for elements of any type.
+func (q *Queue) Distinct(eq func(a, b interface{}) bool) {
+	kept := 0
+	for i := 0; i < q.count; i++ {
+		elem := q.buf[q.wrap(q.head+i)]
+		dup := false
+		for j := 0; j < kept && !dup; j++ {
+			dup = eq(q.buf[q.wrap(q.head+j)], elem)
+		}
+		if !dup {
+			q.buf[q.wrap(q.head+kept)] = elem
+			kept++
+		} else if q.onRemove != nil {
+			q.onRemove(elem)
+		}
+	}
+	q.discardBack(q.count - kept)
+	q.shrinkIfNeeded()
+}
+
+// Transform replaces each element of the queue, in order, with the result of calling fn on
+// it.
+func (q *Queue) Transform(fn func(elem interface{}) interface{}) {
+	for i := 0; i < q.count; i++ {
+		modi := q.wrap(q.head + i)
+		elem := fn(q.buf[modi])
+		q.checkElem(elem)
+		q.buf[modi] = elem
+	}
+	q.mods++
+}
+
+// Merge puts all the elements of other on the end of the queue, in order, growing the
+// backing buffer at most once. other is left unchanged.
+func (q *Queue) Merge(other *Queue) {
+	if q.typ != nil || q.validate != nil {
+		for i := 0; i < other.count; i++ {
+			q.checkElem(other.buf[other.wrap(other.head+i)])
+		}
+	}
+	if q.limit > 0 {
+		for i := 0; i < other.count; i++ {
+			q.push(other.buf[other.wrap(other.head+i)])
+		}
+		return
+	}
+	q.Grow(other.count)
+	first, second := other.segments()
+	q.copyIn(first)
+	q.copyIn(second)
+}
+
+// MoveTo removes up to n elements from the front of the queue and puts them on the end of
+// dst, in order, growing dst at most once and shrinking q at most once. It returns the
+// number of elements moved, which is less than n if q holds fewer. It panics if n is
+// negative. dst must not be q.
+func (q *Queue) MoveTo(dst *Queue, n int) int {
+	if n < 0 {
+		panic("index out of range")
+	}
+	if n > q.count {
+		n = q.count
+	}
+	first, second := q.segments()
+	if len(first) >= n {
+		first, second = first[:n], second[:0]
+	} else {
+		second = second[:n-len(first)]
+	}
+	if dst.typ != nil || dst.validate != nil {
+		for i := 0; i < n; i++ {
+			dst.checkElem(q.buf[q.wrap(q.head+i)])
+		}
+	}
+	if dst.limit > 0 {
+		for i := 0; i < n; i++ {
+			dst.push(q.buf[q.wrap(q.head+i)])
+		}
+	} else {
+		dst.Grow(n)
+		dst.copyIn(first)
+		dst.copyIn(second)
+	}
+	q.discard(n)
+	q.shrinkIfNeeded()
+	return n
+}
+
+// Split removes the elements from index n onwards and returns them, in order, in a new
+// queue with the same configuration as q. The first n elements stay in q. It panics if n is
+// negative or greater than the length of the queue.
+func (q *Queue) Split(n int) *Queue {
+	if n > q.count || n < 0 {
+		panic("index out of range")
+	}
+	rest := q.emptyCopy(q.count - n)
+	for i := n; i < q.count; i++ {
+		rest.buf[i-n] = q.buf[q.wrap(q.head+i)]
+	}
+	rest.count = q.count - n
+	rest.tail = rest.wrap(rest.count)
+	rest.maxCount, rest.added = rest.count, uint64(rest.count)
+
+	q.discardBack(q.count - n)
+	q.shrinkIfNeeded()
+	return rest
+}
+
+// Partition returns two new queues with the same configuration as q, holding the elements
+// for which pred returns true and those for which it returns false, each in their original
+// order. It copies rather than drains: q is left unchanged, and can be cleared afterwards if
+// its elements are no longer needed there. pred is called once per element.
+func (q *Queue) Partition(pred func(elem interface{}) bool) (matched, rest *Queue) {
+	matches := make([]bool, q.count)
+	n := 0
+	for i := range matches {
+		if pred(q.buf[q.wrap(q.head+i)]) {
+			matches[i] = true
+			n++
+		}
+	}
+	matched, rest = q.emptyCopy(n), q.emptyCopy(q.count-n)
+	for i, match := range matches {
+		elem := q.buf[q.wrap(q.head+i)]
+		if match {
+			matched.buf[matched.count] = elem
+			matched.count++
+		} else {
+			rest.buf[rest.count] = elem
+			rest.count++
+		}
+	}
+	matched.tail = matched.wrap(matched.count)
+	rest.tail = rest.wrap(rest.count)
+	matched.maxCount, rest.maxCount = matched.count, rest.count
+	matched.added, rest.added = uint64(matched.count), uint64(rest.count)
+	return matched, rest
+}
+
+// emptyCopy returns a new, empty queue with the same configuration as q and room for n
+// elements.
+func (q *Queue) emptyCopy(n int) *Queue {
+	c := *q
+	c.setBuf(q.allocBuf(q.capacityFor(n)))
+	c.head, c.tail, c.count = 0, 0, 0
+	c.resizes, c.mods, c.maxCount, c.below = 0, 0, 0, 0
+	c.added, c.removed = 0, 0
+	return &c
+}
+
+// PeekN returns a new slice holding the first n elements of the queue in order, or all of
+// them if the queue holds fewer than n. The queue itself is left unchanged.
+func (q *Queue) PeekN(n int) []interface{} {
+	if n > q.count {
+		n = q.count
+	}
+	if n < 0 {
+		n = 0
+	}
+	s := make([]interface{}, n)
+	q.copyOut(s)
+	return s
+}
+
+// PopN removes the first n elements of the queue and returns them in a new slice, in order,
+// or removes and returns all of them if the queue holds fewer than n. The queue is shrunk at
+// most once.
+func (q *Queue) PopN(n int) []interface{} {
+	s := q.PeekN(n)
+	q.discard(len(s))
+	q.shrinkIfNeeded()
+	return s
+}
+
+// PopWhile removes elements from the front of the queue for as long as pred returns true
+// for them, and returns them in a new slice, in order. The first element for which pred
+// returns false stays at the head. The queue is shrunk at most once.
+func (q *Queue) PopWhile(pred func(elem interface{}) bool) []interface{} {
+	n := 0
+	for n < q.count && pred(q.buf[q.wrap(q.head+n)]) {
+		n++
+	}
+	return q.PopN(n)
+}
+
+// Drain removes all the elements of the queue and returns a closed channel from which they
+// can be received in order, for handing the contents of the queue to code that consumes a
+// channel. The channel is buffered to hold every element, so no goroutine is involved and
+// the queue is empty as soon as Drain returns.
+func (q *Queue) Drain() <-chan interface{} {
+	c := make(chan interface{}, q.count)
+	for q.count > 0 {
+		c <- q.buf[q.head]
+		q.discard(1)
 	}
+	q.shrinkIfNeeded()
+	close(c)
+	return c
 }
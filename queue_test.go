@@ -1,6 +1,12 @@
 package queue
 
-import "testing"
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+)
 
 func TestQueueLength(t *testing.T) {
 	q := New()
@@ -23,17 +29,3255 @@ func TestQueueLength(t *testing.T) {
 	}
 }
 
+func TestQueueTryPeek(t *testing.T) {
+	q := New()
+
+	if v, ok := q.TryPeek(); ok || v != nil {
+		t.Errorf("peek on empty queue returned %v, %v", v, ok)
+	}
+
+	q.Add(1)
+	q.Add(2)
+	if v, ok := q.TryPeek(); !ok || v.(int) != 1 {
+		t.Errorf("peek returned %v, %v", v, ok)
+	}
+	if q.Length() != 2 {
+		t.Error("peek changed queue length to", q.Length())
+	}
+
+	q.Remove()
+	q.Remove()
+	if _, ok := q.TryPeek(); ok {
+		t.Error("peek on drained queue succeeded")
+	}
+}
+
+func TestQueuePeekWithLen(t *testing.T) {
+	q := New()
+
+	if v, n, ok := q.PeekWithLen(); ok || v != nil || n != 0 {
+		t.Errorf("peek on empty queue returned %v, %d, %v", v, n, ok)
+	}
+
+	q.Add(1)
+	q.Add(2)
+	if v, n, ok := q.PeekWithLen(); !ok || v.(int) != 1 || n != 2 {
+		t.Errorf("peek returned %v, %d, %v", v, n, ok)
+	}
+	q.Remove()
+	if v, n, ok := q.PeekWithLen(); !ok || v.(int) != 2 || n != 1 {
+		t.Errorf("peek after remove returned %v, %d, %v", v, n, ok)
+	}
+}
+
+func TestQueuePeekBack(t *testing.T) {
+	q := New()
+
+	if v, ok := q.TryPeekBack(); ok || v != nil {
+		t.Errorf("peek back on empty queue returned %v, %v", v, ok)
+	}
+
+	// cycle enough elements through that tail wraps back to 0 along the way
+	for i := 0; i < 100; i++ {
+		q.Add(i)
+		if q.PeekBack().(int) != i {
+			t.Errorf("back of queue is %v, expected %d", q.PeekBack(), i)
+		}
+		if v, ok := q.TryPeekBack(); !ok || v.(int) != i {
+			t.Errorf("peek back returned %v, %v", v, ok)
+		}
+		if q.Length() > 10 {
+			q.Remove()
+		}
+	}
+}
+
+func TestQueueCapacity(t *testing.T) {
+	q := New()
+
+	if q.Capacity() != minQueueLen {
+		t.Errorf("new queue has capacity %d, expected %d", q.Capacity(), minQueueLen)
+	}
+	for i := 0; i < 1000; i++ {
+		q.Add(i)
+		if q.Capacity() < q.Length() {
+			t.Errorf("queue with %d elements has capacity %d", q.Length(), q.Capacity())
+		}
+	}
+	for q.Length() > 0 {
+		q.Remove()
+		if q.Capacity() < minQueueLen {
+			t.Errorf("queue shrank to capacity %d", q.Capacity())
+		}
+	}
+}
+
+func TestQueueNewWithCapacity(t *testing.T) {
+	for _, n := range []int{-1, 0, 1, minQueueLen, 1000} {
+		q := NewWithCapacity(n)
+		if q.Length() != 0 {
+			t.Errorf("new queue with capacity %d has length %d", n, q.Length())
+		}
+		if q.Capacity() < n || q.Capacity() < minQueueLen {
+			t.Errorf("new queue with capacity %d has capacity %d", n, q.Capacity())
+		}
+
+		size := q.Capacity()
+		for i := 0; i < n; i++ {
+			q.Add(i)
+		}
+		if q.Capacity() != size {
+			t.Errorf("filling queue with capacity %d grew it to %d", n, q.Capacity())
+		}
+		for i := 0; i < n; i++ {
+			if q.Get(i).(int) != i {
+				t.Errorf("index %d doesn't contain %d", i, i)
+			}
+		}
+	}
+}
+
+func TestQueueFromSlice(t *testing.T) {
+	for _, n := range []int{0, 1, minQueueLen, 1000} {
+		s := make([]interface{}, n)
+		for i := range s {
+			s[i] = i
+		}
+		q := FromSlice(s)
+		if q.Length() != n {
+			t.Errorf("queue from slice of %d has length %d", n, q.Length())
+		}
+		for i := 0; i < n; i++ {
+			if q.Get(i).(int) != i {
+				t.Errorf("index %d doesn't contain %d", i, i)
+			}
+		}
+
+		q.Add(n)
+		if q.Length() != n+1 || q.Get(n).(int) != n {
+			t.Errorf("add after construction from slice of %d failed", n)
+		}
+
+		if n > 0 {
+			s[0] = "changed"
+			if q.Peek() == "changed" {
+				t.Error("queue aliases the source slice")
+			}
+		}
+	}
+}
+
 func TestQueueGet(t *testing.T) {
 	q := New()
 
-	for i := 0; i < 1000; i++ {
+	for i := 0; i < 1000; i++ {
+		q.Add(i)
+		for j := 0; j < q.Length(); j++ {
+			if q.Get(j).(int) != j {
+				t.Errorf("index %d doesn't contain %d", j, j)
+			}
+		}
+	}
+}
+
+func TestQueueAddFrontAll(t *testing.T) {
+	batch := []interface{}{-3, -2, -1}
+	for offset := 0; offset < minQueueLen; offset++ {
+		for _, n := range []int{0, 5, minQueueLen - 3, minQueueLen} {
+			q := New()
+			q.Fill(offset, nil)
+			q.RemoveN(offset)
+			for i := 0; i < n; i++ {
+				q.Add(i)
+			}
+
+			q.AddFrontAll(batch...)
+			if q.Length() != n+3 {
+				t.Fatalf("offset %d length %d: length is %d", offset, n, q.Length())
+			}
+			for i := 0; i < q.Length(); i++ {
+				if q.Get(i).(int) != i-3 {
+					t.Fatalf("offset %d length %d: index %d contains %v", offset, n, i, q.Get(i))
+				}
+			}
+			if err := q.CheckInvariants(); err != nil {
+				t.Fatalf("offset %d length %d: %v", offset, n, err)
+			}
+			q.Verify()
+		}
+	}
+
+	q := New()
+	q.AddFrontAll()
+	if q.Length() != 0 {
+		t.Error("adding nothing changed the length to", q.Length())
+	}
+
+	r := NewRing(4)
+	r.AddAll(0, 1, 2)
+	r.AddFrontAll(-2, -1)
+	if r.String() != "Queue[-2 -1 0 1]" {
+		t.Error("ring holds", r)
+	}
+}
+
+func TestQueueAddFront(t *testing.T) {
+	q := New()
+
+	for i := 0; i < 1000; i++ {
+		q.AddFront(i)
+		if q.Length() != i+1 {
+			t.Error("adding: queue with", i, "elements has length", q.Length())
+		}
+		for j := 0; j < q.Length(); j++ {
+			if q.Get(j).(int) != i-j {
+				t.Errorf("index %d doesn't contain %d", j, i-j)
+			}
+		}
+	}
+}
+
+func TestQueueRemoveBack(t *testing.T) {
+	q := New()
+
+	for i := 0; i < 1000; i++ {
+		q.Add(i)
+	}
+	for i := 999; i >= 0; i-- {
+		if q.Get(q.Length()-1).(int) != i {
+			t.Errorf("back of queue doesn't contain %d", i)
+		}
+		q.RemoveBack()
+		if q.Length() != i {
+			t.Error("removing: queue with", i, "elements has length", q.Length())
+		}
+	}
+}
+
+func TestQueueDequeWrapped(t *testing.T) {
+	q := New()
+
+	// mix both ends so that the live range wraps around the buffer
+	// before and after each resize
+	for i := 0; i < 100; i++ {
+		q.Add(2*i + 1)
+		q.AddFront(-2*i - 1)
+	}
+	for i := 0; i < q.Length(); i++ {
+		if want := 2*i - 199; q.Get(i).(int) != want {
+			t.Fatalf("index %d contains %d, expected %d", i, q.Get(i), want)
+		}
+	}
+	for q.Length() > 1 {
+		front, back := q.Peek().(int), q.Get(q.Length()-1).(int)
+		if front != -back {
+			t.Fatalf("front %d and back %d don't match", front, back)
+		}
+		q.Remove()
+		q.RemoveBack()
+	}
+}
+
+func TestQueueClear(t *testing.T) {
+	q := New()
+
+	for i := 0; i < 100; i++ {
+		q.Add(i)
+	}
+	for i := 0; i < 90; i++ {
+		q.Remove()
+		q.Add(i)
+	}
+	size := len(q.buf)
+	q.Clear()
+	if q.Length() != 0 {
+		t.Error("cleared queue has length", q.Length())
+	}
+	if len(q.buf) != size {
+		t.Errorf("clear changed buffer size from %d to %d", size, len(q.buf))
+	}
+	for i, v := range q.buf {
+		if v != nil {
+			t.Errorf("slot %d still holds %v after clear", i, v)
+		}
+	}
+
+	q.Add(1)
+	if q.Length() != 1 || q.Peek().(int) != 1 {
+		t.Error("cleared queue not reusable")
+	}
+}
+
+func TestQueueReset(t *testing.T) {
+	q := New()
+
+	for i := 0; i < 100; i++ {
+		q.Add(i)
+	}
+	q.Reset()
+	if q.Length() != 0 {
+		t.Error("reset queue has length", q.Length())
+	}
+	if len(q.buf) != minQueueLen {
+		t.Errorf("reset queue has buffer size %d, expected %d", len(q.buf), minQueueLen)
+	}
+
+	q.Add(1)
+	if q.Length() != 1 || q.Peek().(int) != 1 {
+		t.Error("reset queue not reusable")
+	}
+}
+
+func TestQueueToSlice(t *testing.T) {
+	q := New()
+
+	if s := q.ToSlice(); s == nil || len(s) != 0 {
+		t.Errorf("empty queue returned %#v", s)
+	}
+
+	// shift the head along so that the live range wraps around the buffer
+	for i := 0; i < minQueueLen; i++ {
+		q.Add(i)
+	}
+	for i := 0; i < minQueueLen/2; i++ {
+		q.Remove()
+		q.Add(minQueueLen + i)
+	}
+	s := q.ToSlice()
+	if len(s) != q.Length() {
+		t.Fatalf("slice has length %d, expected %d", len(s), q.Length())
+	}
+	for i, v := range s {
+		if v != q.Get(i) {
+			t.Errorf("slice index %d contains %v, queue contains %v", i, v, q.Get(i))
+		}
+	}
+
+	s[0] = "changed"
+	if q.Peek() == "changed" {
+		t.Error("slice aliases the queue buffer")
+	}
+}
+
+func TestQueueDump(t *testing.T) {
+	q := NewWithMinLen(4)
+	if got := q.Dump(); got != "Queue(count 0)[H:T:_ _ _ _]" {
+		t.Error("empty queue dumped as", got)
+	}
+	q.AddAll("a", "b", "c")
+	q.Remove()
+	q.Add("d")
+	if got := q.Dump(); got != "Queue(count 3)[T:_ H:b c d]" {
+		t.Error("wrapped queue dumped as", got)
+	}
+	q.Add("e")
+	if got := q.Dump(); got != "Queue(count 4)[e H:T:b c d]" {
+		t.Error("full queue dumped as", got)
+	}
+
+	q.head, q.tail, q.count = 9, -1, 7
+	if got := q.Dump(); got != "Queue(count 7)[e b c d]" {
+		t.Error("corrupt queue dumped as", got)
+	}
+	q.buf = nil
+	if got := q.Dump(); got != "Queue(count 7)[]" {
+		t.Error("queue without a buffer dumped as", got)
+	}
+}
+
+func TestQueueString(t *testing.T) {
+	q := New()
+
+	if s := q.String(); s != "Queue[]" {
+		t.Errorf("empty queue printed as %q", s)
+	}
+
+	q.Add("x")
+	q.Add("a")
+	q.Add("b")
+	q.Remove()
+	q.Add("c")
+	if s := q.String(); s != "Queue[a b c]" {
+		t.Errorf("queue printed as %q", s)
+	}
+}
+
+func TestQueueCloneWithCapacity(t *testing.T) {
+	q := wrappedQueue(100)
+	for _, tc := range []struct{ capacity, want int }{{0, 128}, {100, 128}, {200, 256}, {1000, 1024}} {
+		c := q.CloneWithCapacity(tc.capacity)
+		if c.Capacity() != tc.want || c.Length() != 100 {
+			t.Errorf("clone with capacity %d has capacity %d and length %d", tc.capacity, c.Capacity(), c.Length())
+		}
+		for i := 0; i < c.Length(); i++ {
+			if c.Get(i).(int) != i {
+				t.Errorf("clone with capacity %d has %v at index %d", tc.capacity, c.Get(i), i)
+			}
+		}
+		if err := c.CheckInvariants(); err != nil {
+			t.Error(err)
+		}
+	}
+
+	q.RemoveN(99)
+	if c := q.CloneWithCapacity(0); c.Capacity() != minQueueLen {
+		t.Error("trimmed clone has capacity", c.Capacity())
+	}
+
+	c := q.CloneWithCapacity(16)
+	c.Fill(15, 0)
+	if err := c.CheckInvariants(); err != nil || c.Capacity() != 16 {
+		t.Errorf("filled clone has capacity %d: %v", c.Capacity(), err)
+	}
+	c.Remove()
+	if q.Length() != 1 || q.Peek().(int) != 99 {
+		t.Error("changing the clone changed the original to", q)
+	}
+}
+
+func TestQueueClone(t *testing.T) {
+	q := New()
+
+	for i := 0; i < 20; i++ {
+		q.Add(i)
+	}
+	q.Remove()
+
+	c := q.Clone()
+	if c.Length() != q.Length() {
+		t.Fatalf("clone has length %d, expected %d", c.Length(), q.Length())
+	}
+	for i := 0; i < q.Length(); i++ {
+		if c.Get(i) != q.Get(i) {
+			t.Errorf("clone index %d contains %v, expected %v", i, c.Get(i), q.Get(i))
+		}
+	}
+
+	q.Add("original")
+	c.Remove()
+	c.Add("clone")
+	if q.Length() != 20 || c.Length() != 19 {
+		t.Errorf("queues have lengths %d and %d after diverging", q.Length(), c.Length())
+	}
+	if q.Peek().(int) != 1 || c.Peek().(int) != 2 {
+		t.Errorf("queues have heads %v and %v after diverging", q.Peek(), c.Peek())
+	}
+	if q.Get(19) != "original" || c.Get(18) != "clone" {
+		t.Error("adding to one queue affected the other")
+	}
+}
+
+func TestQueueSetAutoShrink(t *testing.T) {
+	q := New()
+	q.SetAutoShrink(false)
+
+	for i := 0; i < 1000; i++ {
+		q.Add(i)
+	}
+	size := q.Capacity()
+	for i := 0; i < 500; i++ {
+		q.Remove()
+		q.RemoveBack()
+		if q.Capacity() != size {
+			t.Fatalf("queue shrank from %d to %d with shrinking disabled", size, q.Capacity())
+		}
+	}
+
+	q.SetAutoShrink(true)
+	for i := 0; i < 1000; i++ {
+		q.Add(i)
+	}
+	for i := 0; i < 1000; i++ {
+		q.Remove()
+	}
+	if q.Capacity() != minQueueLen {
+		t.Errorf("queue has capacity %d after draining with shrinking enabled", q.Capacity())
+	}
+}
+
+func TestQueueNewWithPolicy(t *testing.T) {
+	q := NewWithPolicy(4, 0.125)
+
+	for i := 0; i < minQueueLen+1; i++ {
+		q.Add(i)
+	}
+	if q.Capacity() != minQueueLen*4 {
+		t.Errorf("queue grew to capacity %d, expected %d", q.Capacity(), minQueueLen*4)
+	}
+	for q.Length() > minQueueLen/2+1 {
+		q.Remove()
+	}
+	if q.Capacity() != minQueueLen*4 {
+		t.Errorf("queue shrank to capacity %d above the shrink ratio", q.Capacity())
+	}
+	q.Remove()
+	if q.Capacity() != minQueueLen*2 {
+		t.Errorf("queue has capacity %d after dropping to the shrink ratio, expected %d", q.Capacity(), minQueueLen*2)
+	}
+	for i := 0; i < q.Length(); i++ {
+		if q.Get(i).(int) != minQueueLen/2+1+i {
+			t.Errorf("index %d contains %v after shrinking", i, q.Get(i))
+		}
+	}
+
+	for _, policy := range [][2]float64{{1, 0.25}, {0.5, 0.25}, {2, 0}, {2, 1}, {2, -1}} {
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("should panic with policy %v", policy)
+				}
+			}()
+			NewWithPolicy(policy[0], policy[1])
+		}()
+	}
+}
+
+func TestQueueCapacityPowerOfTwo(t *testing.T) {
+	queues := []*Queue{New(), NewWithCapacity(1000), NewWithPolicy(3, 0.1), FromSlice(make([]interface{}, 17))}
+
+	for _, q := range queues {
+		for i := 0; i < 5000; i++ {
+			q.Add(i)
+			if c := q.Capacity(); c&(c-1) != 0 {
+				t.Fatalf("queue grew to capacity %d", c)
+			}
+		}
+		for q.Length() > 0 {
+			q.Remove()
+			if c := q.Capacity(); c&(c-1) != 0 {
+				t.Fatalf("queue shrank to capacity %d", c)
+			}
+		}
+	}
+}
+
+func TestQueueSet(t *testing.T) {
+	q := New()
+
+	// shift the head along so that the live range wraps around the buffer
+	for i := 0; i < minQueueLen; i++ {
+		q.Add(i)
+	}
+	for i := 0; i < minQueueLen/2; i++ {
+		q.Remove()
+		q.Add(i)
+	}
+
+	for i := 0; i < q.Length(); i++ {
+		q.Set(i, -i)
+	}
+	for i := 0; i < q.Length(); i++ {
+		if q.Get(i).(int) != -i {
+			t.Errorf("index %d contains %v, expected %d", i, q.Get(i), -i)
+		}
+	}
+	if q.Length() != minQueueLen {
+		t.Error("set changed queue length to", q.Length())
+	}
+
+	for _, i := range []int{-1, q.Length()} {
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("should panic setting index %d", i)
+				}
+			}()
+			q.Set(i, nil)
+		}()
+	}
+}
+
+func TestQueueNewRing(t *testing.T) {
+	for _, capacity := range []int{1, 10, minQueueLen, 100} {
+		q := NewRing(capacity)
+		size := q.Capacity()
+
+		for i := 0; i < 1000; i++ {
+			q.Add(i)
+			want := i + 1
+			if want > capacity {
+				want = capacity
+			}
+			if q.Length() != want {
+				t.Fatalf("ring of %d has length %d after %d adds", capacity, q.Length(), i+1)
+			}
+			if q.Peek().(int) != i+1-want || q.Get(want-1).(int) != i {
+				t.Fatalf("ring of %d holds %v..%v after adding %d", capacity, q.Peek(), q.Get(want-1), i)
+			}
+		}
+		if q.Capacity() != size {
+			t.Errorf("ring of %d resized from %d to %d", capacity, size, q.Capacity())
+		}
+
+		q.AddFront(-1)
+		if q.Length() != capacity || q.Peek().(int) != -1 {
+			t.Errorf("ring of %d has length %d and head %v after adding to the front", capacity, q.Length(), q.Peek())
+		}
+
+		for q.Length() > 0 {
+			q.Remove()
+		}
+		if q.Capacity() != size {
+			t.Errorf("ring of %d resized from %d to %d when drained", capacity, size, q.Capacity())
+		}
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("should panic with capacity 0")
+		}
+	}()
+	NewRing(0)
+}
+
+func TestQueueAddAll(t *testing.T) {
+	q := New()
+
+	// shift the head along so that the added elements wrap around the buffer
+	for i := 0; i < 10; i++ {
+		q.Add(i)
+	}
+	for i := 0; i < 5; i++ {
+		q.Remove()
+	}
+
+	q.AddAll(10, 11, 12, 13, 14, 15, 16, 17, 18, 19)
+	if q.Length() != 15 || q.Capacity() != minQueueLen {
+		t.Errorf("queue has length %d and capacity %d", q.Length(), q.Capacity())
+	}
+
+	batch := make([]interface{}, 1000)
+	for i := range batch {
+		batch[i] = 20 + i
+	}
+	q.AddAll(batch...)
+	q.AddAll()
+	if q.Length() != 1015 || q.Capacity() != 1024 {
+		t.Errorf("queue has length %d and capacity %d", q.Length(), q.Capacity())
+	}
+	for i := 0; i < q.Length(); i++ {
+		if q.Get(i).(int) != i+5 {
+			t.Fatalf("index %d contains %v, expected %d", i, q.Get(i), i+5)
+		}
+	}
+
+	r := NewRing(3)
+	r.AddAll(1, 2, 3, 4, 5)
+	if r.Length() != 3 || r.Peek().(int) != 3 {
+		t.Errorf("ring has length %d and head %v", r.Length(), r.Peek())
+	}
+}
+
+func TestQueuePeekInto(t *testing.T) {
+	q := wrappedQueue(20)
+	dst := make([]interface{}, 15)
+
+	if n := q.PeekInto(dst); n != 15 {
+		t.Error("peeked", n, "elements")
+	}
+	for i, v := range dst {
+		if v.(int) != i {
+			t.Errorf("index %d of dst contains %v", i, v)
+		}
+	}
+	if q.Length() != 20 {
+		t.Error("peeking changed the length to", q.Length())
+	}
+
+	q.RemoveN(10)
+	dst[10] = -1
+	if n := q.PeekInto(dst); n != 10 || dst[9].(int) != 19 || dst[10].(int) != -1 {
+		t.Errorf("peeked %d elements into %v", n, dst)
+	}
+	if allocs := testing.AllocsPerRun(10, func() { q.PeekInto(dst) }); allocs != 0 {
+		t.Error("peeking allocated", allocs, "times")
+	}
+}
+
+func TestQueueDrainTo(t *testing.T) {
+	q := New()
+
+	for i := 0; i < 100; i++ {
+		q.Add(i)
+	}
+	dst := make([]interface{}, 30)
+	next := 0
+	for q.Length() > 0 {
+		n := q.DrainTo(dst)
+		if want := q.Length() + n; n != 30 && n != want {
+			t.Fatalf("drained %d elements", n)
+		}
+		for i := 0; i < n; i++ {
+			if dst[i].(int) != next {
+				t.Fatalf("drained %v, expected %d", dst[i], next)
+			}
+			next++
+		}
+	}
+	if next != 100 {
+		t.Errorf("drained %d elements in total", next)
+	}
+	if q.Capacity() != minQueueLen {
+		t.Errorf("drained queue has capacity %d", q.Capacity())
+	}
+	for i, v := range q.buf {
+		if v != nil {
+			t.Errorf("slot %d still holds %v after draining", i, v)
+		}
+	}
+
+	if n := q.DrainTo(dst); n != 0 {
+		t.Errorf("drained %d elements from an empty queue", n)
+	}
+	q.Add(1)
+	if n := q.DrainTo(nil); n != 0 || q.Length() != 1 {
+		t.Errorf("drained %d elements into an empty slice", n)
+	}
+}
+
+func TestQueueRemoveN(t *testing.T) {
+	q := New()
+
+	for i := 0; i < 100; i++ {
+		q.Add(i)
+	}
+	q.RemoveN(0)
+	q.RemoveN(10)
+	if q.Length() != 90 || q.Peek().(int) != 10 {
+		t.Errorf("queue has length %d and head %v", q.Length(), q.Peek())
+	}
+	q.RemoveN(85)
+	if q.Length() != 5 || q.Peek().(int) != 95 {
+		t.Errorf("queue has length %d and head %v", q.Length(), q.Peek())
+	}
+	if q.Capacity() != minQueueLen {
+		t.Errorf("queue has capacity %d", q.Capacity())
+	}
+	for i := 0; i < q.Length(); i++ {
+		if q.Get(i).(int) != 95+i {
+			t.Errorf("index %d contains %v", i, q.Get(i))
+		}
+	}
+
+	for _, n := range []int{-1, 6} {
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("should panic removing %d elements", n)
+				}
+			}()
+			q.RemoveN(n)
+		}()
+	}
+
+	q.RemoveN(5)
+	if q.Length() != 0 {
+		t.Error("queue has length", q.Length())
+	}
+	for i, v := range q.buf {
+		if v != nil {
+			t.Errorf("slot %d still holds %v", i, v)
+		}
+	}
+}
+
+func TestQueueIndexOf(t *testing.T) {
+	q := New()
+	eq := func(a, b interface{}) bool { return a.([]int)[0] == b.([]int)[0] }
+
+	if q.IndexOf([]int{0}, eq) != -1 || q.Contains([]int{0}, eq) {
+		t.Error("empty queue contains an element")
+	}
+
+	// shift the head along so that the live range wraps around the buffer, and use
+	// slices since they can't be compared with ==
+	for i := 0; i < minQueueLen; i++ {
+		q.Add([]int{i})
+	}
+	for i := 0; i < minQueueLen/2; i++ {
+		q.Remove()
+		q.Add([]int{i})
+	}
+
+	for i := 0; i < minQueueLen; i++ {
+		want := (i + minQueueLen/2) % minQueueLen
+		if j := q.IndexOf([]int{i}, eq); j != want || !q.Contains([]int{i}, eq) {
+			t.Errorf("found %d at index %d, expected %d", i, j, want)
+		}
+	}
+	if q.IndexOf([]int{minQueueLen}, eq) != -1 || q.Contains([]int{minQueueLen}, eq) {
+		t.Error("queue contains a missing element")
+	}
+
+	q.Add([]int{0})
+	if j := q.IndexOf([]int{0}, eq); j != minQueueLen/2 {
+		t.Errorf("found duplicate at index %d, expected the first match", j)
+	}
+}
+
+func TestQueueGrow(t *testing.T) {
+	q := New()
+
+	// shift the head along so that the live range wraps over the resize
+	for i := 0; i < 10; i++ {
+		q.Add(i)
+	}
+	q.RemoveN(8)
+	for i := 10; i < 20; i++ {
+		q.Add(i)
+	}
+
+	q.Grow(0)
+	q.Grow(minQueueLen - q.Length())
+	if q.Capacity() != minQueueLen {
+		t.Errorf("growing into free space resized to %d", q.Capacity())
+	}
+
+	q.Grow(1000)
+	size := q.Capacity()
+	if size < q.Length()+1000 {
+		t.Fatalf("queue grew to %d with %d elements", size, q.Length())
+	}
+	for i := 0; i < q.Length(); i++ {
+		if q.Get(i).(int) != i+8 {
+			t.Errorf("index %d contains %v after growing", i, q.Get(i))
+		}
+	}
+	for i := 0; i < 1000; i++ {
+		q.Add(i)
+	}
+	if q.Capacity() != size {
+		t.Errorf("queue resized from %d to %d after growing", size, q.Capacity())
+	}
+
+	r := NewRing(5)
+	r.Grow(100)
+	if r.Capacity() != 16 || r.ResizeCount() != 0 {
+		t.Errorf("ring grew to %d", r.Capacity())
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("should panic growing by a negative number")
+		}
+	}()
+	q.Grow(-1)
+}
+
+func TestQueueCompact(t *testing.T) {
+	q := New()
+	q.SetAutoShrink(false)
+
+	for i := 0; i < 1000; i++ {
+		q.Add(i)
+	}
+	q.RemoveN(990)
+	q.AddAll(1000, 1001, 1002, 1003, 1004, 1005, 1006, 1007, 1008, 1009)
+
+	if freed := q.Compact(); freed != 1024-32 {
+		t.Error("compacting freed", freed, "slots")
+	}
+	if q.Capacity() != 32 {
+		t.Errorf("compacted queue of %d elements has capacity %d", q.Length(), q.Capacity())
+	}
+	for i := 0; i < q.Length(); i++ {
+		if q.Get(i).(int) != 990+i {
+			t.Errorf("index %d contains %v after compacting", i, q.Get(i))
+		}
+	}
+
+	buf := q.buf
+	if freed := q.Compact(); freed != 0 {
+		t.Error("compacting a tight queue freed", freed, "slots")
+	}
+	if &q.buf[0] != &buf[0] {
+		t.Error("compacting a tight queue reallocated it")
+	}
+
+	q.Clear()
+	q.Compact()
+	if q.Capacity() != minQueueLen {
+		t.Errorf("compacted empty queue has capacity %d", q.Capacity())
+	}
+
+	r := NewRing(100)
+	r.AddAll(1, 2, 3)
+	if freed := r.Compact(); freed != 0 || r.Capacity() != 128 {
+		t.Errorf("compacting a ring freed %d slots, leaving capacity %d", freed, r.Capacity())
+	}
+	r.ShrinkTo(0)
+	r.ReserveExact(0)
+	r.ReserveExact(1000)
+	if r.Capacity() != 128 || r.NextGrowCapacity() != 128 {
+		t.Error("resizing a ring changed its capacity to", r.Capacity())
+	}
+	r.Fill(100, 0)
+	r.Add(0)
+	if r.ResizeCount() != 0 {
+		t.Error("ring was resized", r.ResizeCount(), "times")
+	}
+}
+
+func TestQueueShrinkTo(t *testing.T) {
+	q := New()
+	q.SetAutoShrink(false)
+	q.Fill(1000, nil)
+	for i := 0; i < q.Length(); i++ {
+		q.Set(i, i)
+	}
+	q.RemoveN(900)
+
+	q.ShrinkTo(200)
+	if q.Capacity() != 256 {
+		t.Error("shrinking to 200 left capacity", q.Capacity())
+	}
+	for i := 0; i < q.Length(); i++ {
+		if q.Get(i).(int) != 900+i {
+			t.Errorf("index %d contains %v after shrinking", i, q.Get(i))
+		}
+	}
+
+	q.ShrinkTo(10)
+	if q.Capacity() != 128 {
+		t.Errorf("shrinking below the length of %d left capacity %d", q.Length(), q.Capacity())
+	}
+	buf := q.buf
+	q.ShrinkTo(1000)
+	if q.Capacity() != 128 || &q.buf[0] != &buf[0] {
+		t.Error("shrinking to a larger capacity reallocated the queue to capacity", q.Capacity())
+	}
+
+	q.Clear()
+	q.ShrinkTo(0)
+	if q.Capacity() != minQueueLen {
+		t.Errorf("shrinking an empty queue left capacity %d", q.Capacity())
+	}
+}
+
+func TestQueueCompare(t *testing.T) {
+	cmp := func(a, b interface{}) int { return 10 * (a.(int) - b.(int)) }
+	build := func(elems ...interface{}) *Queue {
+		q := New()
+		q.Fill(14, nil)
+		q.RemoveN(14) // elements wrap around the end of the buffer
+		q.AddAll(elems...)
+		return q
+	}
+
+	for _, tc := range []struct {
+		a, b *Queue
+		want int
+	}{
+		{New(), New(), 0},
+		{build(1, 2, 3), FromSlice([]interface{}{1, 2, 3}), 0},
+		{build(1, 2, 3), build(1, 3), -1},
+		{build(2), build(1, 3), +1},
+		{build(1, 2), build(1, 2, 3), -1},
+		{build(1, 2, 3), build(1, 2), +1},
+		{New(), build(1), -1},
+	} {
+		if got := tc.a.Compare(tc.b, cmp); got != tc.want {
+			t.Errorf("comparing %v and %v returned %d, expected %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestQueueEqual(t *testing.T) {
+	eq := func(a, b interface{}) bool { return a == b }
+	q, other := New(), New()
+
+	if !q.Equal(other, eq) {
+		t.Error("empty queues are not equal")
+	}
+
+	// reach the same contents through different histories, so the heads differ
+	for i := 0; i < 10; i++ {
+		q.Add(i)
+	}
+	for i := 0; i < 30; i++ {
+		other.Add(-1)
+	}
+	other.RemoveN(25)
+	other.RemoveN(5)
+	for i := 9; i >= 0; i-- {
+		other.AddFront(i)
+	}
+	if !q.Equal(other, eq) || !other.Equal(q, eq) {
+		t.Errorf("%v and %v are not equal", q, other)
+	}
+
+	other.Set(5, -1)
+	if q.Equal(other, eq) {
+		t.Errorf("%v and %v are equal", q, other)
+	}
+	other.Set(5, 5)
+	other.Add(10)
+	if q.Equal(other, eq) || other.Equal(q, eq) {
+		t.Errorf("%v and %v are equal", q, other)
+	}
+}
+
+func TestQueueForEach(t *testing.T) {
+	q := New()
+
+	q.ForEach(func(i int, elem interface{}) bool {
+		t.Error("empty queue visited an element")
+		return true
+	})
+
+	// shift the head along so that the live range wraps around the buffer
+	for i := 0; i < minQueueLen; i++ {
+		q.Add(i)
+	}
+	q.RemoveN(minQueueLen / 2)
+	for i := 0; i < minQueueLen/2; i++ {
+		q.Add(minQueueLen + i)
+	}
+
+	n := 0
+	q.ForEach(func(i int, elem interface{}) bool {
+		if i != n || elem.(int) != minQueueLen/2+i {
+			t.Errorf("visited index %d with %v", i, elem)
+		}
+		n++
+		return true
+	})
+	if n != q.Length() {
+		t.Errorf("visited %d elements, expected %d", n, q.Length())
+	}
+
+	n = 0
+	q.ForEach(func(i int, elem interface{}) bool {
+		n++
+		return i < 2
+	})
+	if n != 3 {
+		t.Errorf("visited %d elements before stopping", n)
+	}
+}
+
+// wrappedQueue returns a queue holding 0..n-1 whose live range wraps around the end of its
+// backing buffer.
+func wrappedQueue(n int) *Queue {
+	q := NewWithCapacity(n)
+	offset := q.Capacity() - n/2
+	for i := 0; i < offset; i++ {
+		q.Add(nil)
+	}
+	q.RemoveN(offset)
+	for i := 0; i < n; i++ {
+		q.Add(i)
+	}
+	return q
+}
+
+// emptySlots returns the number of nil slots in the backing buffer of q.
+func emptySlots(q *Queue) int {
+	n := 0
+	for _, v := range q.buf {
+		if v == nil {
+			n++
+		}
+	}
+	return n
+}
+
+func TestQueueRemoveAt(t *testing.T) {
+	for _, n := range []int{1, 2, 10, minQueueLen} {
+		for i := 0; i < n; i++ {
+			q := wrappedQueue(n)
+			q.RemoveAt(i)
+			if q.Length() != n-1 {
+				t.Fatalf("removing %d of %d left length %d", i, n, q.Length())
+			}
+			for j := 0; j < q.Length(); j++ {
+				want := j
+				if j >= i {
+					want = j + 1
+				}
+				if q.Get(j).(int) != want {
+					t.Errorf("removing %d of %d left %v at index %d", i, n, q.Get(j), j)
+				}
+			}
+			if nils := emptySlots(q); nils != q.Capacity()-q.Length() {
+				t.Errorf("removing %d of %d left %d empty slots", i, n, nils)
+			}
+		}
+	}
+
+	q := wrappedQueue(3)
+	for _, i := range []int{-1, 3} {
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("should panic removing index %d", i)
+				}
+			}()
+			q.RemoveAt(i)
+		}()
+	}
+}
+
+func TestQueueInsert(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 10, minQueueLen} {
+		for i := 0; i <= n; i++ {
+			q := wrappedQueue(n)
+			q.Insert(i, -1)
+			if q.Length() != n+1 {
+				t.Fatalf("inserting at %d of %d left length %d", i, n, q.Length())
+			}
+			for j := 0; j < q.Length(); j++ {
+				want := j
+				if j == i {
+					want = -1
+				} else if j > i {
+					want = j - 1
+				}
+				if q.Get(j).(int) != want {
+					t.Errorf("inserting at %d of %d left %v at index %d", i, n, q.Get(j), j)
+				}
+			}
+			if q.PeekBack() != q.Get(q.Length()-1) {
+				t.Errorf("inserting at %d of %d left back %v", i, n, q.PeekBack())
+			}
+		}
+	}
+
+	r := NewRing(3)
+	r.AddAll(1, 2, 3)
+	r.Insert(1, -1)
+	if r.String() != "Queue[-1 2 3]" {
+		t.Errorf("inserting into a full ring left %v", r)
+	}
+	r = NewRing(3)
+	r.AddAll(2, 3, 4)
+	r.Insert(0, 0)
+	if r.String() != "Queue[0 2 3]" {
+		t.Errorf("inserting at the front of a full ring left %v", r)
+	}
+
+	q := wrappedQueue(3)
+	for _, i := range []int{-1, 4} {
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("should panic inserting at index %d", i)
+				}
+			}()
+			q.Insert(i, nil)
+		}()
+	}
+}
+
+func TestQueueSwap(t *testing.T) {
+	q := wrappedQueue(10)
+
+	for i := 0; i < 5; i++ {
+		q.Swap(i, 9-i)
+	}
+	for i := 0; i < 10; i++ {
+		if q.Get(i).(int) != 9-i {
+			t.Errorf("index %d contains %v after swapping", i, q.Get(i))
+		}
+	}
+	q.Swap(3, 3)
+	if q.Get(3).(int) != 6 || q.Length() != 10 {
+		t.Error("swapping an index with itself changed the queue")
+	}
+
+	for _, ij := range [][2]int{{-1, 0}, {0, -1}, {10, 0}, {0, 10}} {
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("should panic swapping %v", ij)
+				}
+			}()
+			q.Swap(ij[0], ij[1])
+		}()
+	}
+}
+
+func TestQueueRotate(t *testing.T) {
+	for _, size := range []int{1, 10, minQueueLen} {
+		for n := -2 * size; n <= 2*size; n++ {
+			q := wrappedQueue(size)
+			q.Rotate(n)
+			if q.Length() != size {
+				t.Fatalf("rotating %d of %d left length %d", n, size, q.Length())
+			}
+			shift := ((n % size) + size) % size
+			for i := 0; i < size; i++ {
+				if want := (i + shift) % size; q.Get(i).(int) != want {
+					t.Fatalf("rotating %d of %d left %v at index %d, expected %d", n, size, q.Get(i), i, want)
+				}
+			}
+			if q.PeekBack() != q.Get(size-1) {
+				t.Errorf("rotating %d of %d left back %v", n, size, q.PeekBack())
+			}
+			if nils := emptySlots(q); nils != q.Capacity()-q.Length() {
+				t.Errorf("rotating %d of %d left %d empty slots", n, size, nils)
+			}
+		}
+	}
+
+	q := New()
+	q.Rotate(3)
+	if q.Length() != 0 {
+		t.Error("rotating an empty queue changed its length")
+	}
+}
+
+func TestQueueReverse(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 9, 10, minQueueLen} {
+		q := wrappedQueue(n)
+		back, _ := q.TryPeekBack()
+		q.Reverse()
+		if q.Length() != n {
+			t.Fatalf("reversing %d elements left length %d", n, q.Length())
+		}
+		for i := 0; i < n; i++ {
+			if q.Get(i).(int) != n-1-i {
+				t.Errorf("reversing %d elements left %v at index %d", n, q.Get(i), i)
+			}
+		}
+		if front, _ := q.TryPeek(); front != back {
+			t.Errorf("reversing %d elements left head %v, expected %v", n, front, back)
+		}
+	}
+}
+
+func TestQueueFilter(t *testing.T) {
+	even := func(elem interface{}) bool { return elem.(int)%2 == 0 }
+
+	for _, n := range []int{0, 1, 10, minQueueLen} {
+		q := wrappedQueue(n)
+		q.Filter(even)
+		if q.Length() != (n+1)/2 {
+			t.Fatalf("filtering %d elements left length %d", n, q.Length())
+		}
+		for i := 0; i < q.Length(); i++ {
+			if q.Get(i).(int) != 2*i {
+				t.Errorf("filtering %d elements left %v at index %d", n, q.Get(i), i)
+			}
+		}
+		if q.Length() > 0 && q.PeekBack() != q.Get(q.Length()-1) {
+			t.Errorf("filtering %d elements left back %v", n, q.PeekBack())
+		}
+		if nils := emptySlots(q); nils != q.Capacity()-q.Length() {
+			t.Errorf("filtering %d elements left %d empty slots", n, nils)
+		}
+	}
+
+	q := wrappedQueue(10)
+	q.Filter(func(interface{}) bool { return true })
+	if q.Length() != 10 {
+		t.Error("keeping everything left length", q.Length())
+	}
+	q.Filter(func(interface{}) bool { return false })
+	if q.Length() != 0 {
+		t.Error("removing everything left length", q.Length())
+	}
+	q.Add(1)
+	if q.Peek().(int) != 1 || q.Length() != 1 {
+		t.Error("queue unusable after removing everything")
+	}
+}
+
+func TestQueueTransform(t *testing.T) {
+	q := wrappedQueue(minQueueLen)
+
+	var seen []int
+	q.Transform(func(elem interface{}) interface{} {
+		seen = append(seen, elem.(int))
+		return elem.(int) * 10
+	})
+	if q.Length() != minQueueLen {
+		t.Error("transforming changed queue length to", q.Length())
+	}
+	for i := 0; i < q.Length(); i++ {
+		if seen[i] != i || q.Get(i).(int) != 10*i {
+			t.Errorf("index %d saw %d and contains %v", i, seen[i], q.Get(i))
+		}
+	}
+}
+
+func TestQueueMerge(t *testing.T) {
+	for _, n := range []int{0, 1, 10, minQueueLen} {
+		for _, m := range []int{0, 1, 10, minQueueLen, 100} {
+			q, other := wrappedQueue(n), wrappedQueue(m)
+			q.Merge(other)
+			if q.Length() != n+m || other.Length() != m {
+				t.Fatalf("merging %d into %d left lengths %d and %d", m, n, q.Length(), other.Length())
+			}
+			for i := 0; i < q.Length(); i++ {
+				want := i
+				if i >= n {
+					want = i - n
+				}
+				if q.Get(i).(int) != want {
+					t.Errorf("merging %d into %d left %v at index %d", m, n, q.Get(i), i)
+				}
+			}
+		}
+	}
+
+	q := wrappedQueue(10)
+	q.Merge(q)
+	if q.Length() != 20 || q.Get(10).(int) != 0 || q.PeekBack().(int) != 9 {
+		t.Errorf("merging a queue into itself left %v", q)
+	}
+
+	r := NewRing(3)
+	r.Add(0)
+	r.Merge(wrappedQueue(5))
+	if r.String() != "Queue[2 3 4]" {
+		t.Errorf("merging into a ring left %v", r)
+	}
+}
+
+func TestQueueSplit(t *testing.T) {
+	for _, size := range []int{0, 1, 10, minQueueLen} {
+		for n := 0; n <= size; n++ {
+			q := wrappedQueue(size)
+			rest := q.Split(n)
+			if q.Length() != n || rest.Length() != size-n {
+				t.Fatalf("splitting %d at %d left lengths %d and %d", size, n, q.Length(), rest.Length())
+			}
+			for i := 0; i < n; i++ {
+				if q.Get(i).(int) != i {
+					t.Errorf("splitting %d at %d left %v at index %d", size, n, q.Get(i), i)
+				}
+			}
+			for i := 0; i < rest.Length(); i++ {
+				if rest.Get(i).(int) != n+i {
+					t.Errorf("splitting %d at %d returned %v at index %d", size, n, rest.Get(i), i)
+				}
+			}
+			if nils := emptySlots(q); nils != q.Capacity()-q.Length() {
+				t.Errorf("splitting %d at %d left %d empty slots", size, n, nils)
+			}
+
+			rest.Add(-1)
+			q.Add(-2)
+			if rest.PeekBack().(int) != -1 || q.PeekBack().(int) != -2 {
+				t.Errorf("split queues are not independent")
+			}
+		}
+	}
+
+	q := wrappedQueue(3)
+	for _, n := range []int{-1, 4} {
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("should panic splitting at %d", n)
+				}
+			}()
+			q.Split(n)
+		}()
+	}
+}
+
+func TestQueuePeekN(t *testing.T) {
+	q := wrappedQueue(10)
+
+	for _, n := range []int{-1, 0, 1, 5, 10, 11} {
+		s := q.PeekN(n)
+		want := n
+		if want < 0 {
+			want = 0
+		} else if want > 10 {
+			want = 10
+		}
+		if s == nil || len(s) != want {
+			t.Fatalf("peeking %d returned %#v", n, s)
+		}
+		for i, v := range s {
+			if v.(int) != i {
+				t.Errorf("peeking %d returned %v at index %d", n, v, i)
+			}
+		}
+	}
+	if q.Length() != 10 {
+		t.Error("peeking changed queue length to", q.Length())
+	}
+	if s := New().PeekN(3); s == nil || len(s) != 0 {
+		t.Errorf("peeking empty queue returned %#v", s)
+	}
+}
+
+func TestQueueResetPooled(t *testing.T) {
+	pool := sync.Pool{New: func() interface{} { return New() }}
+
+	for round := 0; round < 3; round++ {
+		q := pool.Get().(*Queue)
+		if q.Length() != 0 || q.Capacity() != minQueueLen {
+			t.Fatalf("pooled queue has length %d and capacity %d", q.Length(), q.Capacity())
+		}
+		for i := 0; i < 1000; i++ {
+			q.Add(i)
+		}
+		q.Reset()
+		if emptySlots(q) != q.Capacity() {
+			t.Error("reset queue still holds elements")
+		}
+		pool.Put(q)
+	}
+
+	r := NewRing(100)
+	size := r.Capacity()
+	r.AddAll(1, 2, 3)
+	r.Reset()
+	if r.Length() != 0 || r.Capacity() != size {
+		t.Errorf("reset ring has length %d and capacity %d", r.Length(), r.Capacity())
+	}
+}
+
+func TestQueueResizeCount(t *testing.T) {
+	q := New()
+
+	for i := 0; i < 64; i++ {
+		q.Add(i)
+	}
+	if q.ResizeCount() != 2 {
+		t.Errorf("growing to 64 took %d resizes", q.ResizeCount())
+	}
+	for q.Length() > 0 {
+		q.Remove()
+	}
+	if q.ResizeCount() != 4 {
+		t.Errorf("growing and draining took %d resizes", q.ResizeCount())
+	}
+
+	q = NewWithCapacity(10000)
+	for i := 0; i < 10000; i++ {
+		q.Add(i)
+	}
+	if q.ResizeCount() != 0 {
+		t.Errorf("filling a preallocated queue took %d resizes", q.ResizeCount())
+	}
+}
+
+func TestQueueSetOnResize(t *testing.T) {
+	q := New()
+
+	var resizes [][2]int
+	q.SetOnResize(func(oldCap, newCap int) {
+		if q.Capacity() != newCap {
+			t.Errorf("called with new capacity %d while queue has %d", newCap, q.Capacity())
+		}
+		resizes = append(resizes, [2]int{oldCap, newCap})
+	})
+	for i := 0; i < 64; i++ {
+		q.Add(i)
+	}
+	for q.Length() > 0 {
+		q.Remove()
+	}
+	want := [][2]int{{16, 32}, {32, 64}, {64, 32}, {32, 16}}
+	if fmt.Sprint(resizes) != fmt.Sprint(want) {
+		t.Errorf("observed resizes %v, expected %v", resizes, want)
+	}
+
+	q.SetOnResize(nil)
+	for i := 0; i < 64; i++ {
+		q.Add(i)
+	}
+	if len(resizes) != len(want) {
+		t.Error("removed function was still called")
+	}
+}
+
+func TestQueueGetRange(t *testing.T) {
+	q := wrappedQueue(minQueueLen)
+
+	for start := 0; start <= q.Length(); start++ {
+		for end := start; end <= q.Length(); end++ {
+			s := q.GetRange(start, end)
+			if len(s) != end-start {
+				t.Fatalf("range %d:%d has length %d", start, end, len(s))
+			}
+			for i, v := range s {
+				if v.(int) != start+i {
+					t.Errorf("range %d:%d has %v at index %d", start, end, v, i)
+				}
+			}
+		}
+	}
+
+	for _, rng := range [][2]int{{-1, 2}, {0, minQueueLen + 1}, {3, 2}} {
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("should panic getting range %v", rng)
+				}
+			}()
+			q.GetRange(rng[0], rng[1])
+		}()
+	}
+}
+
+func TestQueueNewWithMinLen(t *testing.T) {
+	for _, c := range []struct{ minLen, capacity int }{{1, 1}, {2, 2}, {3, 4}, {32, 32}} {
+		q := NewWithMinLen(c.minLen)
+		if q.Capacity() != c.capacity {
+			t.Errorf("queue with minimum %d has capacity %d, expected %d", c.minLen, q.Capacity(), c.capacity)
+		}
+		for i := 0; i < 100; i++ {
+			q.Add(i)
+		}
+		for i := 0; i < 100; i++ {
+			if q.Pop().(int) != i {
+				t.Fatalf("queue with minimum %d lost element %d", c.minLen, i)
+			}
+		}
+		if q.Capacity() != c.capacity {
+			t.Errorf("drained queue with minimum %d has capacity %d, expected %d", c.minLen, q.Capacity(), c.capacity)
+		}
+		q.AddAll(1, 2, 3)
+		q.Reset()
+		if q.Capacity() != c.capacity {
+			t.Errorf("reset queue with minimum %d has capacity %d, expected %d", c.minLen, q.Capacity(), c.capacity)
+		}
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("should panic with minimum 0")
+		}
+	}()
+	NewWithMinLen(0)
+}
+
+func TestQueueAddIfRoom(t *testing.T) {
+	q := NewWithCapacity(32)
+
+	for i := 0; i < 32; i++ {
+		if !q.AddIfRoom(i) {
+			t.Fatalf("adding element %d to a queue of capacity 32 failed", i)
+		}
+	}
+	if q.AddIfRoom(32) {
+		t.Error("adding to a full queue succeeded")
+	}
+	if q.Length() != 32 || q.Capacity() != 32 || q.PeekBack().(int) != 31 {
+		t.Errorf("failed add changed the queue to %v", q)
+	}
+	q.Remove()
+	if !q.AddIfRoom(32) || q.PeekBack().(int) != 32 {
+		t.Error("adding after making room failed")
+	}
+
+	r := NewRing(3)
+	for i := 0; i < 3; i++ {
+		r.AddIfRoom(i)
+	}
+	if r.AddIfRoom(3) || r.String() != "Queue[0 1 2]" {
+		t.Errorf("adding to a full ring evicted an element, leaving %v", r)
+	}
+}
+
+func TestQueueSetZeroOnRemove(t *testing.T) {
+	q := New()
+	q.SetZeroOnRemove(false)
+
+	for i := 0; i < 10; i++ {
+		q.Add(i)
+	}
+	q.Remove()
+	q.RemoveBack()
+	q.RemoveN(2)
+	if emptySlots(q) != q.Capacity()-10 {
+		t.Error("removing cleared slots with zeroing disabled")
+	}
+	for i := 0; i < q.Length(); i++ {
+		if q.Get(i).(int) != i+3 {
+			t.Errorf("index %d contains %v", i, q.Get(i))
+		}
+	}
+	q.Clear()
+	if emptySlots(q) != q.Capacity()-10 {
+		t.Error("clearing cleared slots with zeroing disabled")
+	}
+
+	q.SetZeroOnRemove(true)
+	q.AddAll(1, 2, 3)
+	q.Remove()
+	if q.buf[0] != nil {
+		t.Error("removing kept the slot with zeroing enabled")
+	}
+}
+
+func TestQueueDrain(t *testing.T) {
+	q := wrappedQueue(100)
+
+	c := q.Drain()
+	if q.Length() != 0 || q.Capacity() != minQueueLen {
+		t.Errorf("drained queue has length %d and capacity %d", q.Length(), q.Capacity())
+	}
+	i := 0
+	for v := range c {
+		if v.(int) != i {
+			t.Errorf("received %v, expected %d", v, i)
+		}
+		i++
+	}
+	if i != 100 {
+		t.Errorf("received %d elements", i)
+	}
+
+	for range q.Drain() {
+		t.Error("empty queue sent an element")
+	}
+}
+
+func TestQueueFill(t *testing.T) {
+	q := wrappedQueue(10)
+
+	q.Fill(0, -1)
+	q.Fill(1000, -1)
+	if q.Length() != 1010 || q.ResizeCount() != 1 {
+		t.Errorf("filling left length %d after %d resizes", q.Length(), q.ResizeCount())
+	}
+	for i := 0; i < q.Length(); i++ {
+		want := -1
+		if i < 10 {
+			want = i
+		}
+		if q.Get(i).(int) != want {
+			t.Fatalf("index %d contains %v after filling", i, q.Get(i))
+		}
+	}
+
+	r := NewRing(5)
+	r.AddAll(1, 2, 3)
+	r.Fill(3, 0)
+	if r.String() != "Queue[2 3 0 0 0]" {
+		t.Errorf("filling a ring left %v", r)
+	}
+	r.Fill(100, 9)
+	if r.String() != "Queue[9 9 9 9 9]" {
+		t.Errorf("overfilling a ring left %v", r)
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("should panic filling a negative number")
+		}
+	}()
+	q.Fill(-1, nil)
+}
+
+func TestQueueMinMax(t *testing.T) {
+	less := func(a, b interface{}) bool { return a.(int) < b.(int) }
+	q := New()
+
+	if _, ok := q.Min(less); ok {
+		t.Error("min of empty queue succeeded")
+	}
+	if _, ok := q.Max(less); ok {
+		t.Error("max of empty queue succeeded")
+	}
+
+	q = wrappedQueue(10)
+	q.Set(3, -5)
+	q.Set(7, 50)
+	if v, ok := q.Min(less); !ok || v.(int) != -5 {
+		t.Errorf("min returned %v, %v", v, ok)
+	}
+	if v, ok := q.Max(less); !ok || v.(int) != 50 {
+		t.Errorf("max returned %v, %v", v, ok)
+	}
+}
+
+func TestQueueAddTracked(t *testing.T) {
+	q := New()
+
+	for i := 0; i < 1000; i++ {
+		full := q.Length() == q.Capacity()
+		if resized := q.AddTracked(i); resized != full {
+			t.Fatalf("add %d reported resize %v, want %v", i, resized, full)
+		}
+	}
+	if q.Length() != 1000 || q.Get(999).(int) != 999 {
+		t.Error("tracked adds did not add")
+	}
+}
+
+func TestQueuePopWhile(t *testing.T) {
+	q := wrappedQueue(10)
+	small := func(elem interface{}) bool { return elem.(int) < 4 }
+
+	if got := q.PopWhile(small); fmt.Sprint(got) != "[0 1 2 3]" {
+		t.Error("popped", got)
+	}
+	if q.Length() != 6 || q.Peek().(int) != 4 {
+		t.Errorf("popping left %v", q)
+	}
+	if got := q.PopWhile(small); len(got) != 0 || q.Length() != 6 {
+		t.Errorf("popping with a failing head returned %v and left %v", got, q)
+	}
+
+	calls := 0
+	got := q.PopWhile(func(interface{}) bool { calls++; return true })
+	if len(got) != 6 || q.Length() != 0 || calls != 6 {
+		t.Errorf("popping everything returned %v after %d calls", got, calls)
+	}
+	if got := q.PopWhile(small); len(got) != 0 {
+		t.Error("popping from an empty queue returned", got)
+	}
+}
+
+func TestQueuePopN(t *testing.T) {
+	q := wrappedQueue(100)
+
+	s := q.PopN(30)
+	if len(s) != 30 || q.Length() != 70 {
+		t.Fatalf("popped %d elements, leaving %d", len(s), q.Length())
+	}
+	for i, v := range s {
+		if v.(int) != i {
+			t.Errorf("popped %v at index %d", v, i)
+		}
+	}
+	if q.Peek().(int) != 30 {
+		t.Error("head is now", q.Peek())
+	}
+	if n := emptySlots(q); n != q.Capacity()-70 {
+		t.Errorf("pop left %d empty slots", n)
+	}
+
+	if s := q.PopN(-1); len(s) != 0 || q.Length() != 70 {
+		t.Error("popping a negative number changed the queue")
+	}
+	if s := q.PopN(1000); len(s) != 70 || q.Length() != 0 || q.Capacity() != minQueueLen {
+		t.Errorf("popping everything left length %d and capacity %d", q.Length(), q.Capacity())
+	}
+}
+
+func TestQueueFull(t *testing.T) {
+	q := New()
+
+	for i := 0; i < minQueueLen; i++ {
+		if q.Full() {
+			t.Fatal("queue full with length", q.Length())
+		}
+		q.Add(i)
+	}
+	if !q.Full() {
+		t.Error("queue not full at capacity")
+	}
+	q.Grow(1)
+	if q.Full() {
+		t.Error("queue full after growing")
+	}
+	if q.AddTracked(minQueueLen) {
+		t.Error("add resized after growing")
+	}
+
+	r := NewRing(3)
+	r.AddAll(1, 2, 3, 4)
+	if r.Full() {
+		t.Error("ring reported full")
+	}
+}
+
+func TestQueuePeekContiguous(t *testing.T) {
+	q := wrappedQueue(100)
+
+	var got []interface{}
+	for q.Length() > 0 {
+		s := q.PeekContiguous()
+		if len(s) == 0 {
+			t.Fatal("empty run with length", q.Length())
+		}
+		got = append(got, s...)
+		q.RemoveN(len(s))
+	}
+	if len(got) != 100 {
+		t.Fatal("consumed", len(got))
+	}
+	for i, v := range got {
+		if v.(int) != i {
+			t.Errorf("consumed %v at index %d", v, i)
+		}
+	}
+	if len(q.PeekContiguous()) != 0 {
+		t.Error("empty queue returned a run")
+	}
+}
+
+func TestQueueSegments(t *testing.T) {
+	q := NewWithCapacity(100)
+	q.SetAutoShrink(false)
+	q.Fill(78, nil)
+	q.RemoveN(78)
+	for i := 0; i < 100; i++ {
+		q.Add(i)
+	}
+	first, second := q.Segments()
+	if len(first) != 50 || len(second) != 50 {
+		t.Fatalf("wrapped queue has segments of %d and %d elements", len(first), len(second))
+	}
+	for i, v := range append(first, second...) {
+		if v.(int) != i {
+			t.Errorf("index %d of the segments contains %v", i, v)
+		}
+	}
+	first[0] = -1
+	if q.Peek().(int) != -1 {
+		t.Error("segments don't alias the queue")
+	}
+
+	q.EnsureContiguous()
+	if first, second = q.Segments(); len(first) != 100 || len(second) != 0 {
+		t.Errorf("contiguous queue has segments of %d and %d elements", len(first), len(second))
+	}
+	if first, second = New().Segments(); len(first) != 0 || len(second) != 0 {
+		t.Error("empty queue has segments", first, second)
+	}
+}
+
+func TestQueueWriteContiguous(t *testing.T) {
+	q := wrappedQueue(10)
+
+	next := 10
+	for next < 100 {
+		s := q.WriteContiguous(100 - next)
+		if len(s) == 0 {
+			t.Fatal("no room at length", q.Length())
+		}
+		n := 0
+		for ; n < len(s) && next < 100; n++ {
+			s[n] = next
+			next++
+		}
+		q.Commit(n)
+	}
+	if q.Length() != 100 || q.Capacity() != 128 {
+		t.Fatalf("queue has length %d and capacity %d", q.Length(), q.Capacity())
+	}
+	for i := 0; i < 100; i++ {
+		if q.Get(i).(int) != i {
+			t.Errorf("index %d contains %v", i, q.Get(i))
+		}
+	}
+
+	q.Commit(0)
+	for _, n := range []int{-1, 29} {
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("should panic committing %d", n)
+				}
+			}()
+			q.Commit(n)
+		}()
+	}
+
+	r := NewRing(4)
+	r.Add(0)
+	s := r.WriteContiguous(100)
+	if len(s) != 3 || r.Capacity() != minQueueLen || r.ResizeCount() != 0 {
+		t.Errorf("ring returned %d slots and has capacity %d", len(s), r.Capacity())
+	}
+	for i := range s {
+		s[i] = i + 1
+	}
+	r.Commit(len(s))
+	if r.String() != "Queue[0 1 2 3]" {
+		t.Error("ring holds", r)
+	}
+	if s := r.WriteContiguous(1); len(s) != 0 {
+		t.Error("full ring returned", len(s), "slots")
+	}
+}
+
+func TestQueueCopyTo(t *testing.T) {
+	q := wrappedQueue(100)
+	dst := NewWithCapacity(200)
+	dst.AddAll(-1, -2, -3)
+
+	buf := dst.buf
+	q.CopyTo(dst)
+	if !dst.Equal(q, func(a, b interface{}) bool { return a == b }) {
+		t.Errorf("copy holds %v", dst)
+	}
+	if &dst.buf[0] != &buf[0] {
+		t.Error("copy reallocated a large enough buffer")
+	}
+	if allocs := testing.AllocsPerRun(10, func() { q.CopyTo(dst) }); allocs != 0 {
+		t.Error("copying allocated", allocs, "times")
+	}
+
+	small := New()
+	q.CopyTo(small)
+	if small.Length() != 100 || small.Get(99).(int) != 99 {
+		t.Errorf("copy into a small queue holds %v", small)
+	}
+
+	q.CopyTo(q)
+	if q.Length() != 100 {
+		t.Error("copying a queue onto itself changed its length to", q.Length())
+	}
+}
+
+func TestQueueTruncate(t *testing.T) {
+	q := wrappedQueue(10)
+	q.Truncate(20)
+	q.TruncateFront(10)
+	if q.Length() != 10 {
+		t.Fatal("truncating to more than the length changed it to", q.Length())
+	}
+
+	q.Truncate(6)
+	if q.String() != "Queue[0 1 2 3 4 5]" {
+		t.Errorf("truncate left %v", q)
+	}
+	q.TruncateFront(3)
+	if q.String() != "Queue[3 4 5]" {
+		t.Errorf("truncate front left %v", q)
+	}
+	if n := emptySlots(q); n != q.Capacity()-3 {
+		t.Errorf("truncating left %d empty slots", n)
+	}
+
+	q = wrappedQueue(1000)
+	q.Truncate(10)
+	if q.Capacity() != minQueueLen*2 {
+		t.Error("truncate left capacity", q.Capacity())
+	}
+
+	for _, truncate := range []func(int){q.Truncate, q.TruncateFront} {
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Error("should panic truncating to a negative length")
+				}
+			}()
+			truncate(-1)
+		}()
+	}
+}
+
+func TestQueueShrinkHysteresis(t *testing.T) {
+	// hover around the shrink threshold of a 128 slot buffer: each time the queue drops to
+	// a quarter full it shrinks, and refilling it to 65 grows it right back
+	oscillate := func(q *Queue) int {
+		q.Fill(65, nil)
+		resizes := q.ResizeCount()
+		for i := 0; i < 100; i++ {
+			for q.Length() > 31 {
+				q.Remove()
+			}
+			for q.Length() < 65 {
+				q.Add(nil)
+			}
+		}
+		return q.ResizeCount() - resizes
+	}
+
+	if n := oscillate(New()); n != 200 {
+		t.Errorf("without hysteresis the queue resized %d times", n)
+	}
+	q := New()
+	q.SetShrinkHysteresis(5)
+	if n := oscillate(q); n != 0 {
+		t.Errorf("with hysteresis the queue resized %d times", n)
+	}
+
+	for q.Length() > 0 {
+		q.Remove()
+	}
+	if q.Capacity() != minQueueLen {
+		t.Error("drained queue has capacity", q.Capacity())
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("should panic on negative hysteresis")
+		}
+	}()
+	q.SetShrinkHysteresis(-1)
+}
+
+func TestQueueHeadTailIndex(t *testing.T) {
+	q := New()
+	if q.HeadIndex() != 0 || q.TailIndex() != 0 {
+		t.Error("new queue has head", q.HeadIndex(), "and tail", q.TailIndex())
+	}
+
+	q = wrappedQueue(10)
+	if q.TailIndex() != (q.HeadIndex()+10)%q.Capacity() {
+		t.Error("queue has head", q.HeadIndex(), "and tail", q.TailIndex())
+	}
+	for i := 0; i < 10; i++ {
+		if q.buf[(q.HeadIndex()+i)%q.Capacity()] != q.Get(i) {
+			t.Error("head index does not point at the head for index", i)
+		}
+	}
+}
+
+func TestQueueNormalize(t *testing.T) {
+	for offset := 0; offset < minQueueLen; offset++ {
+		for _, n := range []int{0, 1, 5, minQueueLen} {
+			q := New()
+			q.Fill(offset, nil)
+			q.RemoveN(offset)
+			for i := 0; i < n; i++ {
+				q.Add(i)
+			}
+			buf := q.buf
+
+			q.Normalize()
+			if q.HeadIndex() != 0 || &q.buf[0] != &buf[0] {
+				t.Fatalf("offset %d length %d: head is at %d after normalizing", offset, n, q.HeadIndex())
+			}
+			for i := 0; i < n; i++ {
+				if q.buf[i].(int) != i {
+					t.Fatalf("offset %d length %d: slot %d contains %v", offset, n, i, q.buf[i])
+				}
+			}
+			if err := q.CheckInvariants(); err != nil {
+				t.Fatalf("offset %d length %d: %v", offset, n, err)
+			}
+			q.Verify()
+		}
+	}
+}
+
+func TestQueueEnsureContiguous(t *testing.T) {
+	for _, n := range []int{0, 5, 10, minQueueLen} {
+		q := wrappedQueue(n)
+		for q.Length() < n {
+			q.Add(q.Length())
+		}
+		s := q.EnsureContiguous()
+		if len(s) != n || q.Length() != n {
+			t.Fatalf("contiguous view of %d elements has length %d", n, len(s))
+		}
+		for i, v := range s {
+			if v.(int) != i || q.Get(i).(int) != i {
+				t.Errorf("index %d contains %v", i, v)
+			}
+		}
+		if first := q.PeekContiguous(); len(first) != n {
+			t.Errorf("queue of %d elements still wraps", n)
+		}
+		if n := emptySlots(q); n != q.Capacity()-len(s) {
+			t.Errorf("contiguous queue has %d empty slots", n)
+		}
+		q.Add(n)
+		if q.PeekBack().(int) != n {
+			t.Error("add after making contiguous put", q.PeekBack(), "at the back")
+		}
+	}
+}
+
+func TestQueueHighWaterMark(t *testing.T) {
+	q := New()
+	if q.HighWaterMark() != 0 {
+		t.Error("new queue has high water mark", q.HighWaterMark())
+	}
+
+	for i := 0; i < 100; i++ {
+		q.Add(i)
+	}
+	for i := 0; i < 90; i++ {
+		q.Remove()
+	}
+	q.AddAll(1, 2, 3)
+	if q.HighWaterMark() != 100 {
+		t.Error("high water mark is", q.HighWaterMark())
+	}
+
+	adds := []func(){
+		func() { q.AddFront(0) },
+		func() { q.Insert(1, 0) },
+		func() { q.AddAll(0, 0) },
+		func() { q.Fill(2, 0) },
+		func() { q.Merge(FromSlice([]interface{}{0, 0})) },
+		func() { q.WriteContiguous(1)[0] = 0; q.Commit(1) },
+	}
+	for i, add := range adds {
+		q.TruncateFront(0)
+		q.Fill(q.HighWaterMark(), 0)
+		want := q.HighWaterMark() + 1
+		add()
+		if q.HighWaterMark() < want || q.HighWaterMark() != q.Length() {
+			t.Errorf("add %d left high water mark %d at length %d", i, q.HighWaterMark(), q.Length())
+		}
+	}
+
+	if FromSlice(make([]interface{}, 5)).HighWaterMark() != 5 {
+		t.Error("queue from slice has wrong high water mark")
+	}
+}
+
+func TestQueueGetOK(t *testing.T) {
+	q := wrappedQueue(10)
+
+	for i := 0; i < 10; i++ {
+		if v, ok := q.GetOK(i); !ok || v.(int) != i {
+			t.Errorf("index %d returned %v, %v", i, v, ok)
+		}
+	}
+	for _, i := range []int{-1, 10, 100} {
+		if v, ok := q.GetOK(i); ok || v != nil {
+			t.Errorf("invalid index %d returned %v, %v", i, v, ok)
+		}
+	}
+}
+
+func TestQueueReserveExact(t *testing.T) {
+	q := wrappedQueue(10)
+
+	q.ReserveExact(1000)
+	if q.Capacity() != 1024 {
+		t.Error("reserving 1000 gave capacity", q.Capacity())
+	}
+	for i := 0; i < 10; i++ {
+		if q.Get(i).(int) != i {
+			t.Errorf("index %d contains %v after reserving", i, q.Get(i))
+		}
+	}
+
+	resizes := q.ResizeCount()
+	q.AddAll(make([]interface{}, 500)...)
+	q.Grow(490)
+	q.AddAll(make([]interface{}, 490)...)
+	if q.ResizeCount() != resizes {
+		t.Error("adding up to the reserved size resized the queue")
+	}
+
+	q = NewWithCapacity(4096)
+	q.AddAll(1, 2, 3)
+	q.ReserveExact(100)
+	if q.Capacity() != 128 || q.Length() != 3 {
+		t.Errorf("reserving 100 left capacity %d and length %d", q.Capacity(), q.Length())
+	}
+	q.ReserveExact(0)
+	if q.Capacity() != minQueueLen || q.String() != "Queue[1 2 3]" {
+		t.Errorf("reserving 0 left capacity %d and %v", q.Capacity(), q)
+	}
+
+	q = wrappedQueue(100)
+	q.ReserveExact(10)
+	if q.Capacity() != 128 || q.Length() != 100 {
+		t.Errorf("reserving less than the length left capacity %d and length %d", q.Capacity(), q.Length())
+	}
+}
+
+func TestQueueRemoveIf(t *testing.T) {
+	even := func(head interface{}) bool { return head.(int)%2 == 0 }
+	q := New()
+	if v, ok := q.RemoveIf(even); ok || v != nil {
+		t.Errorf("empty queue returned %v, %v", v, ok)
+	}
+
+	q.AddAll(0, 2, 3, 4)
+	for _, want := range []int{0, 2} {
+		if v, ok := q.RemoveIf(even); !ok || v.(int) != want {
+			t.Errorf("removed %v, %v, expected %d", v, ok, want)
+		}
+	}
+	if v, ok := q.RemoveIf(even); ok || v != nil || q.Length() != 2 || q.Peek().(int) != 3 {
+		t.Errorf("non-matching head returned %v, %v and left %v", v, ok, q)
+	}
+}
+
+func TestQueueRemoveFirst(t *testing.T) {
+	q := wrappedQueue(10)
+
+	if v, ok := q.RemoveFirst(func(elem interface{}) bool { return elem.(int) > 3 }); !ok || v.(int) != 4 {
+		t.Errorf("remove first returned %v, %v", v, ok)
+	}
+	if v, ok := q.RemoveFirst(func(elem interface{}) bool { return elem.(int) > 100 }); ok || v != nil {
+		t.Errorf("remove first without a match returned %v, %v", v, ok)
+	}
+	if q.String() != "Queue[0 1 2 3 5 6 7 8 9]" {
+		t.Errorf("remove first left %v", q)
+	}
+}
+
+func TestQueueCountIf(t *testing.T) {
+	even := func(elem interface{}) bool { return elem.(int)%2 == 0 }
+
+	if n := New().CountIf(even); n != 0 {
+		t.Error("empty queue counted", n)
+	}
+	if n := wrappedQueue(11).CountIf(even); n != 6 {
+		t.Error("counted", n, "even elements")
+	}
+}
+
+func TestQueueSetOnRemove(t *testing.T) {
+	var removed []int
+	q := New()
+	q.SetOnRemove(func(elem interface{}) { removed = append(removed, elem.(int)) })
+	for i := 0; i < 20; i++ {
+		q.Add(i)
+	}
+
+	q.Remove()
+	q.RemoveN(2)
+	q.RemoveBack()
+	q.RemoveAt(1)
+	q.Truncate(13)
+	q.Filter(func(elem interface{}) bool { return elem.(int) != 6 })
+	if v := q.Pop(); v.(int) != 3 {
+		t.Error("popped", v)
+	}
+	if v, ok := q.RemoveFirst(func(elem interface{}) bool { return elem.(int) == 7 }); !ok || v.(int) != 7 {
+		t.Error("removed first", v, ok)
+	}
+	q.Clear()
+	want := []int{0, 1, 2, 19, 4, 17, 18, 6, 5, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	if fmt.Sprint(removed) != fmt.Sprint(want) {
+		t.Errorf("removed %v, expected %v", removed, want)
+	}
+
+	for i := 0; i < 10; i++ {
+		q.Add(i)
+	}
+	removed = removed[:0]
+	q.SetOnRemove(nil)
+	q.Clear()
+	if len(removed) != 0 {
+		t.Error("removed hook still called after unsetting it")
+	}
+}
+
+func TestQueueOnEvict(t *testing.T) {
+	var evicted []interface{}
+	r := NewRing(3)
+	r.SetOnEvict(func(elem interface{}) { evicted = append(evicted, elem) })
+
+	r.AddAll(1, 2, 3)
+	if len(evicted) != 0 {
+		t.Fatal("evicted", evicted, "before the ring was full")
+	}
+	r.Add(4)
+	r.AddFront(0)
+	r.Insert(1, 9)
+	r.Fill(2, 5)
+	r.Merge(FromSlice([]interface{}{6}))
+	if fmt.Sprint(evicted) != "[1 4 0 9 2 3]" {
+		t.Errorf("evicted %v", evicted)
+	}
+	if r.String() != "Queue[5 5 6]" {
+		t.Errorf("ring holds %v", r)
+	}
+
+	r.SetOnEvict(nil)
+	r.Add(7)
+	if len(evicted) != 6 || r.String() != "Queue[5 6 7]" {
+		t.Error("removing the callback did not silence it")
+	}
+}
+
+func TestQueueSwapWith(t *testing.T) {
+	full, empty := wrappedQueue(100), New()
+	buf := full.buf
+
+	empty.SwapWith(full)
+	if full.Length() != 0 || empty.Length() != 100 || &empty.buf[0] != &buf[0] {
+		t.Fatalf("swap left lengths %d and %d", full.Length(), empty.Length())
+	}
+	for i := 0; i < 100; i++ {
+		if empty.Get(i).(int) != i {
+			t.Errorf("index %d contains %v after swapping", i, empty.Get(i))
+		}
+	}
+	full.Add(1)
+	empty.Add(100)
+	if full.String() != "Queue[1]" || empty.PeekBack().(int) != 100 {
+		t.Error("swapped queues not usable")
+	}
+
+	r := NewRing(3)
+	r.SwapWith(FromSlice([]interface{}{1, 2}))
+	if r.String() != "Queue[1 2]" {
+		t.Errorf("ring holds %v after swapping", r)
+	}
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("should panic swapping too many elements into a ring")
+		}
+	}()
+	empty.SwapWith(r)
+}
+
+func TestQueueSwapWithChecks(t *testing.T) {
+	shouldPanic := func(name string, q, other *Queue) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("should panic swapping", name)
+			}
+		}()
+		q.SwapWith(other)
+	}
+
+	strs := FromSlice([]interface{}{"str"})
+	typed := NewTyped(0)
+	shouldPanic("elements of the wrong type", typed, strs)
+	shouldPanic("elements of the wrong type", strs, typed)
+
+	v := New()
+	v.SetValidator(func(interface{}) error { return errors.New("rejected") })
+	shouldPanic("rejected elements", v, strs)
+
+	shouldPanic("past the maximum capacity", NewFixed(4), wrappedQueue(100))
+	if typed.Length() != 0 || strs.Length() != 1 || v.Length() != 0 {
+		t.Error("failed swaps changed the queues")
+	}
+
+	ints := FromSlice([]interface{}{1, 2})
+	typed.SwapWith(ints)
+	if typed.String() != "Queue[1 2]" || ints.Length() != 0 {
+		t.Errorf("typed queue holds %v after swapping", typed)
+	}
+}
+
+func TestQueuePartition(t *testing.T) {
+	q := wrappedQueue(10)
+	calls := 0
+	even, odd := q.Partition(func(elem interface{}) bool {
+		calls++
+		return elem.(int)%2 == 0
+	})
+
+	if even.String() != "Queue[0 2 4 6 8]" || odd.String() != "Queue[1 3 5 7 9]" {
+		t.Errorf("partitioned into %v and %v", even, odd)
+	}
+	if calls != 10 {
+		t.Error("predicate called", calls, "times")
+	}
+	if q.Length() != 10 {
+		t.Error("partition changed the length to", q.Length())
+	}
+	even.Add(10)
+	odd.AddFront(-1)
+	if even.PeekBack().(int) != 10 || odd.Peek().(int) != -1 {
+		t.Error("partitioned queues not usable")
+	}
+
+	all, none := wrappedQueue(100).Partition(func(interface{}) bool { return true })
+	if all.Length() != 100 || none.Length() != 0 || all.Capacity() != 128 || none.Capacity() != minQueueLen {
+		t.Errorf("partition sized queues to %d and %d", all.Capacity(), none.Capacity())
+	}
+}
+
+func TestQueueCheckInvariants(t *testing.T) {
+	q := New()
+	check := func(op string) {
+		if err := q.CheckInvariants(); err != nil {
+			t.Fatalf("after %s: %v", op, err)
+		}
+	}
+	q = &Queue{}
+	check("zero value")
+	q = New()
+	check("New")
+	q.Fill(100, 1)
+	check("Fill")
+	q.RemoveN(90)
+	check("RemoveN")
+	q.AddAll(2, 3, 4, 5, 6, 7, 8, 9)
+	check("AddAll")
+	q.AddFront(0)
+	check("AddFront")
+	q.RemoveAt(5)
+	check("RemoveAt")
+	q.Rotate(3)
+	check("Rotate")
+	q.EnsureContiguous()
+	check("EnsureContiguous")
+	q.Truncate(2)
+	check("Truncate")
+	q.Clear()
+	check("Clear")
+	q = NewRing(3)
+	q.Fill(3, nil)
+	q.Add(1)
+	check("ring Add")
+
+	corrupt := []func(q *Queue){
+		func(q *Queue) { q.buf = q.buf[:12] },
+		func(q *Queue) { q.count = -1 },
+		func(q *Queue) { q.count = len(q.buf) + 1 },
+		func(q *Queue) { q.head = len(q.buf) },
+		func(q *Queue) { q.tail = -1 },
+		func(q *Queue) { q.tail = (q.tail + 1) & (len(q.buf) - 1) },
+		func(q *Queue) { q.limit = 1 },
+		func(q *Queue) { q.mask = 7 },
+	}
+	for i, fn := range corrupt {
+		q := New()
+		q.AddAll(1, 2, 3)
+		fn(q)
+		if err := q.CheckInvariants(); err == nil {
+			t.Errorf("case %d wasn't detected", i)
+		}
+	}
+}
+
+func TestQueueVerify(t *testing.T) {
+	q := wrappedQueue(10)
+	q.Verify()
+
+	mutations := []func(){
+		func() { q.Remove() },
+		func() { q.RemoveBack() },
+		func() { q.RemoveAt(3) },
+		func() { q.RemoveN(2) },
+		func() { q.Filter(func(elem interface{}) bool { return elem.(int)%2 == 0 }) },
+		func() { q.Truncate(1) },
+		func() { q.PopN(1) },
+		func() { q.Clear() },
+	}
+	for i, mutate := range mutations {
+		q = wrappedQueue(10)
+		mutate()
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Errorf("mutation %d: %v", i, r)
+				}
+			}()
+			q.Verify()
+		}()
+	}
+
+	q.SetZeroOnRemove(false)
+	q.Fill(5, 1)
+	q.RemoveN(5)
+	q.Verify()
+
+	q.buf[(q.tail+1)&(len(q.buf)-1)] = "leaked"
+	q.SetZeroOnRemove(true)
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("should panic on a retained element")
+		}
+	}()
+	q.Verify()
+}
+
+func TestConcat(t *testing.T) {
+	if q := Concat(); q.Length() != 0 || q.Capacity() != minQueueLen {
+		t.Errorf("empty concat has length %d and capacity %d", q.Length(), q.Capacity())
+	}
+
+	a, b, c := wrappedQueue(10), New(), wrappedQueue(100)
+	q := Concat(a, b, c)
+	if q.Length() != 110 || q.Capacity() != 128 || q.ResizeCount() != 0 {
+		t.Fatalf("concat has length %d and capacity %d", q.Length(), q.Capacity())
+	}
+	for i := 0; i < 110; i++ {
+		want := i
+		if i >= 10 {
+			want = i - 10
+		}
+		if q.Get(i).(int) != want {
+			t.Errorf("index %d contains %v, want %d", i, q.Get(i), want)
+		}
+	}
+	if a.Length() != 10 || c.Length() != 100 {
+		t.Error("concat changed its sources")
+	}
+}
+
+func TestQueueRemoveUntil(t *testing.T) {
+	q := wrappedQueue(10)
+
+	if n := q.RemoveUntil(func(elem interface{}) bool { return elem.(int) == 100 }); n != 0 || q.Length() != 10 {
+		t.Errorf("remove until without a match removed %d, leaving %d", n, q.Length())
+	}
+	if n := q.RemoveUntil(func(elem interface{}) bool { return elem.(int) == 6 }); n != 7 {
+		t.Error("remove until removed", n)
+	}
+	if q.String() != "Queue[7 8 9]" {
+		t.Errorf("remove until left %v", q)
+	}
+	if n := emptySlots(q); n != q.Capacity()-3 {
+		t.Errorf("remove until left %d empty slots", n)
+	}
+	if n := q.RemoveUntil(func(interface{}) bool { return true }); n != 1 || q.Peek().(int) != 8 {
+		t.Error("remove until the head removed", n)
+	}
+}
+
+func TestQueueDistinct(t *testing.T) {
+	eq := func(a, b interface{}) bool { return a == b }
+	q := wrappedQueue(10)
+	for i := 0; i < 20; i++ {
+		q.Add(i % 7)
+	}
+
+	q.Distinct(eq)
+	if q.Length() != 10 {
+		t.Fatalf("distinct left %d elements: %v", q.Length(), q)
+	}
+	for i := 0; i < q.Length(); i++ {
+		if q.Get(i).(int) != i {
+			t.Errorf("index %d contains %v", i, q.Get(i))
+		}
+	}
+	q.Verify()
+
+	// eq works for uncomparable elements too
+	q = New()
+	q.AddAll([]int{1}, []int{2}, []int{1}, []int{1, 2})
+	q.Distinct(func(a, b interface{}) bool { return fmt.Sprint(a) == fmt.Sprint(b) })
+	if q.String() != "Queue[[1] [2] [1 2]]" {
+		t.Error("distinct left", q)
+	}
+
+	q.Clear()
+	q.Distinct(eq)
+	if q.Length() != 0 {
+		t.Error("distinct on empty queue left", q)
+	}
+}
+
+func TestQueueProcessAndRemove(t *testing.T) {
+	q := wrappedQueue(10)
+
+	var handled []interface{}
+	q.ProcessAndRemove(func(elem interface{}) bool {
+		if elem.(int)%3 != 0 {
+			return false
+		}
+		handled = append(handled, elem)
+		return true
+	})
+	if fmt.Sprint(handled) != "[0 3 6 9]" {
+		t.Errorf("handled %v", handled)
+	}
+	if q.String() != "Queue[1 2 4 5 7 8]" {
+		t.Errorf("process and remove left %v", q)
+	}
+	q.Verify()
+
+	q = wrappedQueue(1000)
+	q.ProcessAndRemove(func(interface{}) bool { return true })
+	if q.Length() != 0 || q.Capacity() != minQueueLen {
+		t.Errorf("removing everything left length %d and capacity %d", q.Length(), q.Capacity())
+	}
+}
+
+func TestQueueEqualUnordered(t *testing.T) {
+	eq := func(a, b interface{}) bool { return a == b }
+
+	q := wrappedQueue(10)
+	other := wrappedQueue(10)
+	other.Reverse()
+	other.Rotate(3)
+	if !q.EqualUnordered(other, eq) {
+		t.Errorf("%v and %v not equal", q, other)
+	}
+	if !New().EqualUnordered(New(), eq) {
+		t.Error("empty queues not equal")
+	}
+
+	for _, elems := range [][]interface{}{
+		{1, 1, 2},
+		{1, 2},
+		{1, 2, 2, 3},
+	} {
+		if FromSlice([]interface{}{1, 2, 2}).EqualUnordered(FromSlice(elems), eq) {
+			t.Errorf("[1 2 2] equal to %v", elems)
+		}
+	}
+}
+
+func TestQueuePeekBackN(t *testing.T) {
+	q := wrappedQueue(10)
+
+	for n := 0; n < 10; n++ {
+		if v, ok := q.PeekBackN(n); !ok || v.(int) != 9-n {
+			t.Errorf("peek back %d returned %v, %v", n, v, ok)
+		}
+	}
+	for _, n := range []int{-1, 10} {
+		if v, ok := q.PeekBackN(n); ok || v != nil {
+			t.Errorf("peek back %d returned %v, %v", n, v, ok)
+		}
+	}
+}
+
+func TestQueueIsWrapped(t *testing.T) {
+	q := New()
+	if q.IsWrapped() {
+		t.Error("empty queue is wrapped")
+	}
+	q.Fill(minQueueLen, 0)
+	if q.IsWrapped() {
+		t.Error("full queue starting at index 0 is wrapped")
+	}
+	q.Remove()
+	q.Add(0)
+	if !q.IsWrapped() {
+		t.Error("full queue starting at index 1 is not wrapped")
+	}
+	q.RemoveBack()
+	if q.IsWrapped() {
+		t.Error("queue ending at the end of the buffer is wrapped")
+	}
+	if !wrappedQueue(10).IsWrapped() {
+		t.Error("wrapped queue is not wrapped")
+	}
+}
+
+func TestQueueVersion(t *testing.T) {
+	q := wrappedQueue(10)
+	less := func(a, b interface{}) bool { return a.(int) < b.(int) }
+
+	mutations := []func(){
+		func() { q.Add(1) },
+		func() { q.AddFront(1) },
+		func() { q.Insert(2, 1) },
+		func() { q.Remove() },
+		func() { q.RemoveBack() },
+		func() { q.RemoveAt(1) },
+		func() { q.Set(0, 5) },
+		func() { q.Swap(0, 1) },
+		func() { q.Rotate(3) },
+		func() { q.Reverse() },
+		func() { q.Sort(less) },
+		func() { q.Transform(func(elem interface{}) interface{} { return elem }) },
+		func() { q.Grow(100) },
+		func() { q.Compact() },
+		func() { q.Filter(func(elem interface{}) bool { return elem.(int) > 0 }) },
+		func() { q.Clear() },
+		func() { q.Reset() },
+	}
+	for i, mutate := range mutations {
+		q.AddAll(3, 1, 4, 1, 5, 9, 2, 6)
+		v := q.Version()
+		mutate()
+		if q.Version() == v {
+			t.Errorf("mutation %d did not change the version", i)
+		}
+	}
+
+	q.AddAll(1, 2, 3)
+	v := q.Version()
+	q.Get(0)
+	q.Peek()
+	q.ToSlice()
+	q.Min(less)
+	if q.Version() != v {
+		t.Error("reading changed the version")
+	}
+}
+
+func TestQueueOrdering(t *testing.T) {
+	// whatever mix of growing and shrinking happens along the way, elements leave the queue
+	// in the order they were added
+	q := New()
+	next, want := 0, 0
+	for round := 0; round < 50; round++ {
+		for i := 0; i < round*7%40; i++ {
+			q.Add(next)
+			next++
+		}
+		for i := 0; i < round*11%37 && q.Length() > 0; i++ {
+			if v := q.Pop().(int); v != want {
+				t.Fatalf("popped %d, want %d", v, want)
+			}
+			want++
+		}
+	}
+	for q.Length() > 0 {
+		if v := q.Pop().(int); v != want {
+			t.Fatalf("popped %d, want %d", v, want)
+		}
+		want++
+	}
+	if want != next {
+		t.Errorf("popped %d of %d elements", want, next)
+	}
+}
+
+func TestQueueRemoveBackN(t *testing.T) {
+	q := wrappedQueue(10)
+
+	q.RemoveBackN(0)
+	q.RemoveBackN(4)
+	if q.String() != "Queue[0 1 2 3 4 5]" {
+		t.Errorf("remove back left %v", q)
+	}
+	q.Verify()
+	q.Add(6)
+	if q.PeekBack().(int) != 6 || q.Length() != 7 {
+		t.Error("add after removing from the back failed")
+	}
+
+	q = wrappedQueue(1000)
+	q.RemoveBackN(1000)
+	if q.Length() != 0 || q.Capacity() != minQueueLen {
+		t.Errorf("removing everything left length %d and capacity %d", q.Length(), q.Capacity())
+	}
+
+	for _, n := range []int{-1, 1} {
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("should panic removing %d from the back", n)
+				}
+			}()
+			q.RemoveBackN(n)
+		}()
+	}
+}
+
+func TestQueueGetMany(t *testing.T) {
+	q := wrappedQueue(10)
+
+	if s := q.GetMany(); len(s) != 0 {
+		t.Error("no indexes returned", s)
+	}
+	if s := q.GetMany(9, 0, 5, 5); fmt.Sprint(s) != "[9 0 5 5]" {
+		t.Error("get many returned", s)
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("should panic when an index is out of range")
+		}
+	}()
+	q.GetMany(0, 10)
+}
+
+func TestQueueCapacityCeiling(t *testing.T) {
+	maxInt := int(^uint(0) >> 1)
+
+	if roundCapacity(maxCapacity, minQueueLen) != maxCapacity {
+		t.Error("the maximum capacity is not a valid capacity")
+	}
+	if maxCapacity != maxInt/2+1 {
+		t.Error("maximum capacity", maxCapacity, "is not the largest power of two")
+	}
+	for _, c := range []struct {
+		n      int
+		factor float64
+		want   int
+	}{
+		{16, 2, 32},
+		{maxCapacity / 2, 2, maxCapacity},
+		{maxCapacity, 2, maxCapacity},
+		{maxCapacity / 2, 1e300, maxCapacity},
+		{maxInt, 1, maxCapacity},
+	} {
+		if got := scaleCapacity(c.n, c.factor); got != c.want || got < 0 {
+			t.Errorf("scaling %d by %v gave %d, want %d", c.n, c.factor, got, c.want)
+		}
+	}
+
+	q := New()
+	q.Add(1)
+	for i, fn := range []func(){
+		func() { roundCapacity(maxCapacity+1, minQueueLen) },
+		func() { q.Grow(maxInt) },
+		func() { q.Grow(maxCapacity) },
+		func() { q.Fill(maxInt, nil) },
+	} {
+		func() {
+			defer func() {
+				if r := recover(); r != "capacity too large" {
+					t.Errorf("case %d panicked with %v", i, r)
+				}
+			}()
+			fn()
+		}()
+	}
+	if q.Length() != 1 || q.Capacity() != minQueueLen {
+		t.Errorf("failed grows left length %d and capacity %d", q.Length(), q.Capacity())
+	}
+}
+
+func TestFlatten(t *testing.T) {
+	q := New()
+	if f := Flatten(q); f.Length() != 0 {
+		t.Error("flattening an empty queue gave", f)
+	}
+
+	q.AddAll(FromSlice([]interface{}{1, 2}), New(), wrappedQueue(3))
+	if f := Flatten(q); f.String() != "Queue[1 2 0 1 2]" {
+		t.Error("flattened to", f)
+	}
+	if q.Length() != 3 {
+		t.Error("flatten changed its source")
+	}
+
+	q.Add(4)
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("should panic flattening a queue holding a non-queue")
+		}
+	}()
+	Flatten(q)
+}
+
+func TestQueueSetGrowthThreshold(t *testing.T) {
+	q := New()
+	q.SetGrowthThreshold(1024)
+	var caps []int
+	q.SetOnResize(func(oldCap, newCap int) { caps = append(caps, newCap) })
+	for i := 0; i < 5000; i++ {
 		q.Add(i)
-		for j := 0; j < q.Length(); j++ {
-			if q.Get(j).(int) != j {
-				t.Errorf("index %d doesn't contain %d", j, j)
+	}
+	if want := "[32 64 128 256 512 1024 1536 2304 3456 5184]"; fmt.Sprint(caps) != want {
+		t.Errorf("grew through capacities %v, expected %v", caps, want)
+	}
+
+	// exercise the modulus indexing with elements wrapping around the end of the buffer
+	q.RemoveN(1000)
+	for i := 5000; i < 6000; i++ {
+		q.Add(i)
+	}
+	if !q.IsWrapped() || q.Capacity() != 5184 {
+		t.Fatalf("queue with capacity %d isn't wrapped", q.Capacity())
+	}
+	q.AddFront(999)
+	q.Insert(1, -1)
+	q.RemoveAt(1)
+	q.Rotate(10)
+	q.Rotate(-10)
+	for i := 0; i < q.Length(); i++ {
+		if q.Get(i).(int) != 999+i {
+			t.Fatalf("index %d contains %v", i, q.Get(i))
+		}
+	}
+	if err := q.CheckInvariants(); err != nil {
+		t.Fatal(err)
+	}
+	s := q.EnsureContiguous()
+	if len(s) != 5001 || s[0].(int) != 999 || s[5000].(int) != 5999 {
+		t.Error("contiguous elements run from", s[0], "to", s[len(s)-1])
+	}
+	q.Verify()
+
+	// small batches still grow geometrically above the threshold
+	resizes := q.ResizeCount()
+	for q.Length() < 20000 {
+		q.AddAll(0)
+	}
+	if n := q.ResizeCount() - resizes; n > 5 {
+		t.Error("adding single-element batches resized", n, "times")
+	}
+
+	for q.Length() > 10 {
+		q.Remove()
+	}
+	if err := q.CheckInvariants(); err != nil || q.Capacity() != 32 {
+		t.Errorf("drained queue has capacity %d: %v", q.Capacity(), err)
+	}
+
+	q = New()
+	q.SetGrowthThreshold(0)
+	q.Fill(5000, nil)
+	if q.Capacity() != 8192 {
+		t.Error("queue without a threshold grew to", q.Capacity())
+	}
+
+	// clearing the threshold rounds the capacity back up to a power of two
+	q = New()
+	q.SetGrowthThreshold(1024)
+	q.Fill(1500, 1)
+	q.SetGrowthThreshold(0)
+	if err := q.CheckInvariants(); err != nil || q.Capacity() != 2048 || q.Length() != 1500 {
+		t.Errorf("clearing the threshold left capacity %d: %v", q.Capacity(), err)
+	}
+
+	q.SetGrowthThreshold(1024)
+	q.Fill(1000, 1)
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("should panic swapping a buffer past the threshold into a queue without it")
+		}
+	}()
+	New().SwapWith(q)
+}
+
+func TestQueueSetMaxCapacity(t *testing.T) {
+	q := New()
+	q.SetMaxCapacity(100)
+
+	for i := 0; i < 64; i++ {
+		if !q.AddCapped(i) {
+			t.Fatal("capped add failed at", i)
+		}
+	}
+	if q.AddCapped(64) {
+		t.Error("capped add grew past the maximum")
+	}
+	if q.Length() != 64 || q.Capacity() != 64 {
+		t.Errorf("queue has length %d and capacity %d", q.Length(), q.Capacity())
+	}
+
+	for i, fn := range []func(){
+		func() { q.Add(1) },
+		func() { q.AddFront(1) },
+		func() { q.AddAll(1) },
+		func() { q.Grow(1) },
+	} {
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("case %d should panic at the maximum capacity", i)
+				}
+			}()
+			fn()
+		}()
+	}
+	if q.Length() != 64 {
+		t.Error("failed adds changed the length to", q.Length())
+	}
+
+	q.Remove()
+	if !q.AddCapped(1) {
+		t.Error("capped add failed after making room")
+	}
+
+	// a large growth factor is clamped rather than overshooting the cap
+	q = NewWithPolicy(8, 0.1)
+	q.SetMaxCapacity(64)
+	for i := 0; i < 17; i++ {
+		q.Add(i)
+	}
+	if q.Capacity() != 64 {
+		t.Error("grow past the cap clamped to", q.Capacity())
+	}
+
+	q.SetMaxCapacity(0)
+	q.Fill(100, nil)
+	if q.Length() != 117 {
+		t.Error("removing the cap did not allow growing")
+	}
+}
+
+func TestQueueReplace(t *testing.T) {
+	q := wrappedQueue(10)
+
+	if old := q.Replace(9, "x"); old.(int) != 9 {
+		t.Error("replace returned", old)
+	}
+	if old := q.Replace(9, "y"); old != "x" || q.PeekBack() != "y" {
+		t.Error("second replace returned", old)
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("should panic when index is out of range")
+		}
+	}()
+	q.Replace(10, nil)
+}
+
+func TestQueueSetPrefault(t *testing.T) {
+	q := wrappedQueue(10)
+	q.SetPrefault(true)
+
+	for i := 10; i < 100000; i++ {
+		q.Add(i)
+	}
+	for i := 0; i < 100000; i++ {
+		if q.Peek().(int) != i {
+			t.Fatalf("head is %v, want %d", q.Peek(), i)
+		}
+		q.Remove()
+	}
+	q.Verify()
+
+	s := make([]interface{}, 10000)
+	s[len(s)-1] = 1
+	prefault(s[:len(s)-1])
+	if s[len(s)-1] != 1 {
+		t.Error("prefault wrote past the end of its slice")
+	}
+	prefault(nil)
+}
+
+func TestQueueNextCapacity(t *testing.T) {
+	for _, q := range []*Queue{New(), NewWithPolicy(4, 0.1), NewWithPolicy(1.5, 0.5), NewWithMinLen(1)} {
+		var resizes [][2]int
+		q.SetOnResize(func(oldCap, newCap int) { resizes = append(resizes, [2]int{oldCap, newCap}) })
+		for i := 0; i < 1000; i++ {
+			want := q.NextGrowCapacity()
+			full := q.Full()
+			resizes = resizes[:0]
+			q.Add(i)
+			if full && (len(resizes) != 1 || resizes[0][1] != want) {
+				t.Fatalf("growing at length %d: predicted %d, got %v", i, want, resizes)
+			}
+		}
+		for q.Length() > 0 {
+			resizes = resizes[:0]
+			q.Remove()
+			// Remove just checked whether to shrink at this length, so the prediction must agree.
+			if next := q.NextShrinkCapacity(); next != q.Capacity() && len(resizes) == 0 {
+				t.Fatalf("at length %d capacity %d the queue predicted a shrink to %d", q.Length(), q.Capacity(), next)
+			}
+		}
+	}
+
+	q := New()
+	q.SetAutoShrink(false)
+	q.Fill(1000, nil)
+	q.RemoveN(990)
+	if q.NextShrinkCapacity() != q.Capacity() {
+		t.Error("a queue that doesn't shrink predicted a shrink")
+	}
+	q.SetAutoShrink(true)
+	if q.NextShrinkCapacity() != 32 {
+		t.Error("predicted a shrink to", q.NextShrinkCapacity())
+	}
+	q.Remove()
+	if q.Capacity() != 32 {
+		t.Error("shrank to", q.Capacity())
+	}
+
+	r := NewRing(10)
+	r.Fill(10, nil)
+	if r.NextGrowCapacity() != r.Capacity() {
+		t.Error("ring predicted a grow to", r.NextGrowCapacity())
+	}
+	q = New()
+	q.SetMaxCapacity(16)
+	if q.NextGrowCapacity() != 16 {
+		t.Error("capped queue predicted a grow to", q.NextGrowCapacity())
+	}
+}
+
+func TestQueueMoveTo(t *testing.T) {
+	src, dst := New(), New()
+	for i := 0; i < 20; i++ {
+		src.Add(i)
+	}
+	src.RemoveN(10)
+	for i := 20; i < 30; i++ {
+		src.Add(i) // wraps around the end of the buffer
+	}
+	dst.Add(-1)
+
+	if n := src.MoveTo(dst, 15); n != 15 {
+		t.Error("moved", n, "elements")
+	}
+	if src.Length() != 5 || dst.Length() != 16 {
+		t.Errorf("lengths after move are %d and %d", src.Length(), dst.Length())
+	}
+	for i := 1; i < dst.Length(); i++ {
+		if dst.Get(i).(int) != 9+i {
+			t.Errorf("destination index %d contains %v", i, dst.Get(i))
+		}
+	}
+	if src.Peek().(int) != 25 {
+		t.Error("source front is", src.Peek())
+	}
+
+	if n := src.MoveTo(dst, 100); n != 5 || src.Length() != 0 || dst.PeekBack().(int) != 29 {
+		t.Errorf("moving more than the length moved %d, back is %v", n, dst.PeekBack())
+	}
+	if n := src.MoveTo(dst, 1); n != 0 || dst.Length() != 21 {
+		t.Error("moving from an empty queue moved", n)
+	}
+
+	ring := NewRing(4)
+	if n := dst.MoveTo(ring, 6); n != 6 || ring.Length() != 4 || ring.Peek().(int) != 11 {
+		t.Errorf("moving into a ring moved %d and left %v", n, ring)
+	}
+}
+
+func TestQueueHash(t *testing.T) {
+	h := func(elem interface{}) uint64 { return uint64(elem.(int)) }
+	q, other := New(), New()
+	for i := 0; i < 10; i++ {
+		other.Add(-1)
+	}
+	other.RemoveN(10) // head of other is now in the middle of its buffer
+	for i := 0; i < 10; i++ {
+		q.Add(i)
+		other.Add(i)
+	}
+
+	if q.Hash(h) != other.Hash(h) {
+		t.Error("equal queues hash differently")
+	}
+	other.Swap(0, 1)
+	if q.Hash(h) == other.Hash(h) {
+		t.Error("differently ordered queues hash the same")
+	}
+	other.Swap(0, 1)
+	other.Add(0)
+	if q.Hash(h) == other.Hash(h) {
+		t.Error("queues of different lengths hash the same")
+	}
+	if New().Hash(h) != NewWithCapacity(100).Hash(h) {
+		t.Error("empty queues hash differently")
+	}
+}
+
+func TestQueueDrainFunc(t *testing.T) {
+	q := New()
+	for i := 0; i < 100; i++ {
+		q.Add(i)
+	}
+
+	errFull := errors.New("sink full")
+	var drained []interface{}
+	err := q.DrainFunc(func(elem interface{}) error {
+		if elem.(int) == 60 {
+			return errFull
+		}
+		drained = append(drained, elem)
+		return nil
+	})
+	if err != errFull || len(drained) != 60 {
+		t.Errorf("failed drain returned %v after draining %d elements", err, len(drained))
+	}
+	if q.Length() != 40 || q.Peek().(int) != 60 {
+		t.Errorf("failed drain left %d elements starting with %v", q.Length(), q.Peek())
+	}
+	if q.Capacity() != 128 {
+		t.Error("failed drain left capacity", q.Capacity())
+	}
+
+	drained = drained[:0]
+	if err := q.DrainFunc(func(elem interface{}) error {
+		drained = append(drained, elem)
+		return nil
+	}); err != nil {
+		t.Error("drain returned", err)
+	}
+	if q.Length() != 0 || len(drained) != 40 || drained[0].(int) != 60 {
+		t.Errorf("drain left %d elements, drained %v", q.Length(), drained)
+	}
+	if q.Capacity() != minQueueLen {
+		t.Error("drain left capacity", q.Capacity())
+	}
+}
+
+func TestNewFixed(t *testing.T) {
+	q := NewFixed(100)
+	if q.Capacity() != 128 {
+		t.Fatal("fixed queue has capacity", q.Capacity())
+	}
+	buf := q.buf
+
+	elem := interface{}(&struct{}{})
+	allocs := testing.AllocsPerRun(10, func() {
+		for i := 0; i < 128; i++ {
+			q.Add(elem)
+		}
+		for q.Length() > 1 {
+			q.Remove()
+		}
+		q.Remove()
+	})
+	if allocs != 0 {
+		t.Error("fill and drain cycle allocated", allocs, "times")
+	}
+	if &q.buf[0] != &buf[0] || q.ResizeCount() != 0 {
+		t.Error("fixed queue was resized")
+	}
+
+	q.Fill(128, elem)
+	if q.AddCapped(elem) || q.AddIfRoom(elem) || q.NextGrowCapacity() != 128 {
+		t.Error("full fixed queue has room to grow")
+	}
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("adding to a full fixed queue should panic")
+			}
+		}()
+		q.Add(elem)
+	}()
+	q.Clear()
+	q.Compact()
+	if q.Capacity() != 128 {
+		t.Error("compacting a fixed queue changed its capacity to", q.Capacity())
+	}
+}
+
+func TestQueueAddAllIndexed(t *testing.T) {
+	q := New()
+	if i := q.AddAllIndexed(0, 1, 2); i != 0 {
+		t.Error("first batch started at index", i)
+	}
+	q.Remove()
+	if i := q.AddAllIndexed(3, 4); i != 2 || q.Get(i).(int) != 3 || q.Get(i+1).(int) != 4 {
+		t.Errorf("second batch started at index %d of %v", i, q)
+	}
+	if i := q.AddAllIndexed(); i != q.Length() {
+		t.Error("empty batch started at index", i)
+	}
+
+	r := NewRing(3)
+	r.AddAll(0, 1)
+	if i := r.AddAllIndexed(2, 3); i != 1 || r.Get(i).(int) != 2 {
+		t.Errorf("ring batch started at index %d of %v", i, r)
+	}
+	if i := r.AddAllIndexed(4, 5, 6, 7); i != -1 {
+		t.Errorf("oversized ring batch started at index %d of %v", i, r)
+	}
+}
+
+func TestQueueSample(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	q := wrappedQueue(10)
+
+	counts := make([]int, 10)
+	for run := 0; run < 10000; run++ {
+		s := q.Sample(3, rng)
+		if len(s) != 3 {
+			t.Fatal("sampled", len(s), "elements")
+		}
+		seen := map[interface{}]bool{}
+		for _, v := range s {
+			if seen[v] {
+				t.Fatalf("sampled %v twice in %v", v, s)
 			}
+			seen[v] = true
+			counts[v.(int)]++
+		}
+	}
+	for i, n := range counts {
+		if n < 2700 || n > 3300 {
+			t.Errorf("element %d was sampled %d times out of 10000, expected about 3000", i, n)
+		}
+	}
+	if q.Length() != 10 || q.Peek().(int) != 0 {
+		t.Error("sampling changed the queue to", q)
+	}
+
+	if s := q.Sample(20, rng); len(s) != 10 {
+		t.Error("oversized sample returned", s)
+	}
+	if s := q.Sample(0, rng); len(s) != 0 {
+		t.Error("empty sample returned", s)
+	}
+}
+
+func TestQueueZeroValueAdd(t *testing.T) {
+	var q Queue
+	for i := 0; i < 100; i++ {
+		q.Add(i)
+	}
+	if q.Length() != 100 || q.Capacity() != 128 {
+		t.Errorf("zero queue has length %d and capacity %d", q.Length(), q.Capacity())
+	}
+	for i := 0; i < 100; i++ {
+		if v := q.Pop(); v.(int) != i {
+			t.Errorf("popped %v, expected %d", v, i)
 		}
 	}
+	if err := q.CheckInvariants(); err != nil {
+		t.Error(err)
+	}
 }
 
 func TestQueueGetOutOfRangePanics(t *testing.T) {
@@ -72,6 +3316,45 @@ func TestQueueGetOutOfRangePanics(t *testing.T) {
 	}()
 }
 
+func TestQueuePop(t *testing.T) {
+	q := New()
+
+	for i := 0; i < 1000; i++ {
+		q.Add(i)
+	}
+	for i := 0; i < 1000; i++ {
+		if v := q.Pop().(int); v != i {
+			t.Errorf("pop %d returned %d", i, v)
+		}
+		if q.Length() != 1000-i-1 {
+			t.Error("popping: queue with", 1000-i-1, "elements has length", q.Length())
+		}
+	}
+}
+
+func TestQueuePopOK(t *testing.T) {
+	q := New()
+
+	if v, ok := q.PopOK(); ok || v != nil {
+		t.Errorf("pop on empty queue returned %v, %v", v, ok)
+	}
+	if q.Length() != 0 {
+		t.Error("failed pop changed queue length to", q.Length())
+	}
+
+	q.Add(1)
+	q.Add(2)
+	if v, ok := q.PopOK(); !ok || v.(int) != 1 {
+		t.Errorf("pop returned %v, %v", v, ok)
+	}
+	if v, ok := q.PopOK(); !ok || v.(int) != 2 {
+		t.Errorf("pop returned %v, %v", v, ok)
+	}
+	if _, ok := q.PopOK(); ok {
+		t.Error("pop on drained queue succeeded")
+	}
+}
+
 // General warning: Go's benchmark utility (go test -bench .) increases the number of
 // iterations until the benchmarks take a reasonable amount of time to run; memory usage
 // is *NOT* considered. On my machine, these benchmarks hit around ~1GB before they've had
@@ -94,3 +3377,59 @@ func BenchmarkQueueTickTock(b *testing.B) {
 		q.Remove()
 	}
 }
+
+// BenchmarkQueueMillion has a twin of the same name in the generic subpackage; run both
+// with -benchmem to compare the boxed and unboxed queues.
+func BenchmarkQueueMillion(b *testing.B) {
+	for n := 0; n < b.N; n++ {
+		q := New()
+		for i := 0; i < 1000000; i++ {
+			q.Add(i)
+		}
+		for i := 0; i < 1000000; i++ {
+			q.Remove()
+		}
+	}
+}
+
+func BenchmarkQueueBulkLoad(b *testing.B) {
+	for n := 0; n < b.N; n++ {
+		q := New()
+		for i := 0; i < 10000; i++ {
+			q.Add(nil)
+		}
+	}
+}
+
+func BenchmarkQueueBulkLoadPreallocated(b *testing.B) {
+	for n := 0; n < b.N; n++ {
+		q := NewWithCapacity(10000)
+		for i := 0; i < 10000; i++ {
+			q.Add(nil)
+		}
+	}
+}
+
+func BenchmarkQueueGet(b *testing.B) {
+	q := New()
+	for i := 0; i < 1000; i++ {
+		q.Add(i)
+		q.Remove()
+	}
+	for i := 0; i < 1000; i++ {
+		q.Add(i)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		q.Get(i & 511)
+	}
+}
+
+func BenchmarkQueueTickTockNoZero(b *testing.B) {
+	q := New()
+	q.SetZeroOnRemove(false)
+	for i := 0; i < b.N; i++ {
+		q.Add(nil)
+		q.Remove()
+	}
+}
@@ -0,0 +1,41 @@
+package queue
+
+import "errors"
+
+var (
+	// ErrEmpty is returned by the Safe methods when the queue has no elements.
+	ErrEmpty = errors.New("queue: empty queue")
+	// ErrIndexOutOfRange is returned by the Safe methods when an index is outside
+	// [0, Length()).
+	ErrIndexOutOfRange = errors.New("queue: index out of range")
+)
+
+// The Safe methods mirror Peek, Remove and Get but return an error instead of panicking or
+// returning garbage, for queues driven by input that can't be trusted to be well-formed.
+
+// SafePeek returns the element at the head of the queue, or ErrEmpty if the queue is empty.
+func (q *Queue) SafePeek() (interface{}, error) {
+	if q.count == 0 {
+		return nil, ErrEmpty
+	}
+	return q.buf[q.head], nil
+}
+
+// SafeRemove removes the element from the front of the queue, or returns ErrEmpty and leaves
+// the queue untouched if it is empty.
+func (q *Queue) SafeRemove() error {
+	if q.count == 0 {
+		return ErrEmpty
+	}
+	q.Remove()
+	return nil
+}
+
+// SafeGet returns the element at index i in the queue, or ErrIndexOutOfRange if the index
+// is invalid.
+func (q *Queue) SafeGet(i int) (interface{}, error) {
+	if i >= q.count || i < 0 {
+		return nil, ErrIndexOutOfRange
+	}
+	return q.buf[q.wrap(q.head+i)], nil
+}
@@ -0,0 +1,39 @@
+package queue
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestQueueSafe(t *testing.T) {
+	q := New()
+
+	if _, err := q.SafePeek(); !errors.Is(err, ErrEmpty) {
+		t.Error("peek on empty queue returned", err)
+	}
+	if err := q.SafeRemove(); !errors.Is(err, ErrEmpty) {
+		t.Error("remove on empty queue returned", err)
+	}
+	if q.Length() != 0 {
+		t.Error("failed remove changed the length to", q.Length())
+	}
+	if _, err := q.SafeGet(0); !errors.Is(err, ErrIndexOutOfRange) {
+		t.Error("get on empty queue returned", err)
+	}
+
+	q = wrappedQueue(10)
+	if v, err := q.SafePeek(); err != nil || v.(int) != 0 {
+		t.Errorf("peek returned %v, %v", v, err)
+	}
+	for _, i := range []int{-1, 10} {
+		if _, err := q.SafeGet(i); !errors.Is(err, ErrIndexOutOfRange) {
+			t.Errorf("get(%d) returned %v", i, err)
+		}
+	}
+	if v, err := q.SafeGet(9); err != nil || v.(int) != 9 {
+		t.Errorf("get(9) returned %v, %v", v, err)
+	}
+	if err := q.SafeRemove(); err != nil || q.Peek().(int) != 1 {
+		t.Error("remove returned", err)
+	}
+}
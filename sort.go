@@ -0,0 +1,35 @@
+package queue
+
+import "sort"
+
+type sortView struct {
+	q    *Queue
+	less func(a, b interface{}) bool
+}
+
+func (v sortView) Len() int           { return v.q.Length() }
+func (v sortView) Less(i, j int) bool { return v.less(v.q.Get(i), v.q.Get(j)) }
+func (v sortView) Swap(i, j int)      { v.q.Swap(i, j) }
+
+// SortView returns a sort.Interface over the elements of the queue, ordered by less, so that
+// the queue can be passed to the functions of package sort. Sorting the view sorts the queue
+// in place.
+func (q *Queue) SortView(less func(a, b interface{}) bool) sort.Interface {
+	return sortView{q, less}
+}
+
+// Sort sorts the elements of the queue in place, so that less(Get(i), Get(j)) holds for
+// i < j. The sort is not guaranteed to be stable.
+func (q *Queue) Sort(less func(a, b interface{}) bool) {
+	sort.Sort(q.SortView(less))
+}
+
+// Search uses binary search to find and return the smallest index i in [0, Length()) at
+// which f(Get(i)) is true, assuming that on the range of indexes f is false for some prefix
+// and true for the rest, as with sort.Search. It returns Length() if there is no such index.
+// This is typically used on a sorted queue to find where to Insert a new element.
+func (q *Queue) Search(f func(probe interface{}) bool) int {
+	return sort.Search(q.count, func(i int) bool {
+		return f(q.buf[q.wrap(q.head+i)])
+	})
+}
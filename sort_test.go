@@ -0,0 +1,64 @@
+package queue
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestQueueSort(t *testing.T) {
+	less := func(a, b interface{}) bool { return a.(int) < b.(int) }
+
+	for _, n := range []int{0, 1, 10, minQueueLen, 1000} {
+		q := wrappedQueue(n)
+		// shuffle the elements through the queue's own index mapping
+		rand.Shuffle(n, q.Swap)
+
+		q.Sort(less)
+		for i := 0; i < n; i++ {
+			if q.Get(i).(int) != i {
+				t.Fatalf("index %d contains %v after sorting %d elements", i, q.Get(i), n)
+			}
+		}
+		if n > 0 && (q.Peek().(int) != 0 || q.PeekBack().(int) != n-1) {
+			t.Errorf("sorted queue has head %v and back %v", q.Peek(), q.PeekBack())
+		}
+	}
+
+	q := FromSlice([]interface{}{3, 1, 2})
+	sort.Stable(q.SortView(less))
+	if !sort.IsSorted(q.SortView(less)) || q.String() != "Queue[1 2 3]" {
+		t.Errorf("sorting the view left %v", q)
+	}
+}
+
+func TestQueueSearch(t *testing.T) {
+	q := wrappedQueue(100)
+	for i := 0; i < q.Length(); i++ {
+		q.Set(i, 2*i)
+	}
+
+	for _, x := range []int{-1, 0, 1, 50, 51, 198, 199} {
+		i := q.Search(func(probe interface{}) bool { return probe.(int) >= x })
+		want := (x + 1) / 2
+		if x < 0 {
+			want = 0
+		}
+		if want > 100 {
+			want = 100
+		}
+		if i != want {
+			t.Errorf("search for %d returned %d, want %d", x, i, want)
+		}
+	}
+
+	x := 51
+	q.Insert(q.Search(func(probe interface{}) bool { return probe.(int) >= x }), x)
+	if !sort.IsSorted(q.SortView(func(a, b interface{}) bool { return a.(int) < b.(int) })) {
+		t.Error("inserting at the search result broke the order")
+	}
+
+	if New().Search(func(interface{}) bool { return true }) != 0 {
+		t.Error("search on empty queue did not return 0")
+	}
+}
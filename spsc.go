@@ -0,0 +1,67 @@
+package queue
+
+import "sync/atomic"
+
+// SPSCQueue is a fixed-capacity ring buffer that one producer goroutine and one consumer
+// goroutine can share without locks. The producer publishes the tail and the consumer
+// publishes the head through atomic loads and stores, so each side only ever writes its own
+// index. Since a resize can't be done lock-free, the queue never grows: Push fails when it
+// is full.
+//
+// Pushing from more than one goroutine, or popping from more than one, is a data race.
+type SPSCQueue struct {
+	head uint64 // next slot to pop, written only by the consumer
+	_    [56]byte
+	tail uint64 // next slot to push, written only by the producer
+	_    [56]byte
+	buf  []interface{}
+	mask uint64
+}
+
+// NewSPSC constructs and returns a new SPSCQueue that holds at least capacity elements. The
+// capacity is rounded up to a power of two. It panics if capacity is less than one.
+func NewSPSC(capacity int) *SPSCQueue {
+	if capacity < 1 {
+		panic("SPSC queue capacity must be at least 1")
+	}
+	n := roundCapacity(capacity, 1)
+	return &SPSCQueue{buf: make([]interface{}, n), mask: uint64(n - 1)}
+}
+
+// Capacity returns the number of elements the queue can hold.
+func (s *SPSCQueue) Capacity() int {
+	return len(s.buf)
+}
+
+// Length returns the number of elements currently stored in the queue. When called while the
+// other side is running, the result may be out of date by the time it is returned.
+func (s *SPSCQueue) Length() int {
+	head := atomic.LoadUint64(&s.head)
+	tail := atomic.LoadUint64(&s.tail)
+	return int(tail - head)
+}
+
+// Push puts an element on the end of the queue and returns true, or returns false if the
+// queue is full. It must only be called by the producer.
+func (s *SPSCQueue) Push(elem interface{}) bool {
+	tail := s.tail
+	if tail-atomic.LoadUint64(&s.head) == uint64(len(s.buf)) {
+		return false
+	}
+	s.buf[tail&s.mask] = elem
+	atomic.StoreUint64(&s.tail, tail+1)
+	return true
+}
+
+// Pop removes and returns the element at the head of the queue and true, or returns nil and
+// false if the queue is empty. It must only be called by the consumer.
+func (s *SPSCQueue) Pop() (interface{}, bool) {
+	head := s.head
+	if head == atomic.LoadUint64(&s.tail) {
+		return nil, false
+	}
+	elem := s.buf[head&s.mask]
+	s.buf[head&s.mask] = nil
+	atomic.StoreUint64(&s.head, head+1)
+	return elem, true
+}
@@ -0,0 +1,69 @@
+package queue
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestSPSCQueue(t *testing.T) {
+	s := NewSPSC(5)
+
+	if s.Capacity() != 8 {
+		t.Error("capacity is", s.Capacity())
+	}
+	if _, ok := s.Pop(); ok {
+		t.Error("pop on empty queue succeeded")
+	}
+	for i := 0; i < 8; i++ {
+		if !s.Push(i) {
+			t.Fatal("push failed at", i)
+		}
+	}
+	if s.Push(8) {
+		t.Error("push on full queue succeeded")
+	}
+	if s.Length() != 8 {
+		t.Error("queue has length", s.Length())
+	}
+	for i := 0; i < 8; i++ {
+		if v, ok := s.Pop(); !ok || v.(int) != i {
+			t.Fatalf("pop returned %v, %v, want %d", v, ok, i)
+		}
+	}
+	if s.Length() != 0 {
+		t.Error("queue has length", s.Length())
+	}
+}
+
+func TestSPSCQueueConcurrent(t *testing.T) {
+	const n = 100000
+	s := NewSPSC(64)
+
+	go func() {
+		for i := 0; i < n; i++ {
+			for !s.Push(i) {
+				runtime.Gosched()
+			}
+		}
+	}()
+
+	for i := 0; i < n; i++ {
+		v, ok := s.Pop()
+		for !ok {
+			runtime.Gosched()
+			v, ok = s.Pop()
+		}
+		if v.(int) != i {
+			t.Fatalf("popped %v, want %d", v, i)
+		}
+	}
+}
+
+func TestNewSPSCPanics(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("should panic on zero capacity")
+		}
+	}()
+	NewSPSC(0)
+}
@@ -0,0 +1,26 @@
+package queue
+
+// QueueStats is a snapshot of the counters a queue keeps about how it has been used, as
+// returned by Stats.
+type QueueStats struct {
+	Added   uint64 // elements added, counting each element of a batch
+	Removed uint64 // elements removed, including those popped, cleared or evicted from a ring
+	Length  int    // elements held now
+	Peak    int    // largest number of elements held at once, as reported by HighWaterMark
+	Resizes int    // reallocations of the backing buffer, as reported by ResizeCount
+}
+
+// Stats returns the usage counters of the queue, for exporting to a monitoring system. The
+// counters are plain integers updated as elements are added and removed, so keeping them
+// costs next to nothing. They start from zero for a new queue; a clone starts with the
+// counters of the queue it was cloned from, and SwapWith moves elements without counting
+// them as added or removed.
+func (q *Queue) Stats() QueueStats {
+	return QueueStats{
+		Added:   q.added,
+		Removed: q.removed,
+		Length:  q.count,
+		Peak:    q.maxCount,
+		Resizes: q.resizes,
+	}
+}
@@ -0,0 +1,69 @@
+package queue
+
+import "testing"
+
+func TestQueueStats(t *testing.T) {
+	q := New()
+	if s := q.Stats(); s != (QueueStats{}) {
+		t.Errorf("new queue has stats %+v", s)
+	}
+
+	for i := 0; i < 20; i++ {
+		q.Add(i)
+	}
+	q.AddAll(20, 21, 22)
+	q.AddFront(-1)
+	q.Fill(2, 0)
+	q.Insert(3, 0)
+	q.Remove()
+	q.Pop()
+	q.RemoveN(5)
+	q.RemoveBack()
+	q.RemoveAt(2)
+	q.Filter(func(elem interface{}) bool { return elem.(int) != 10 })
+
+	want := QueueStats{Added: 27, Removed: 10, Length: 17, Peak: 27, Resizes: 1}
+	if s := q.Stats(); s != want {
+		t.Errorf("stats are %+v, expected %+v", s, want)
+	}
+
+	q.Clear()
+	if s := q.Stats(); s.Removed != 27 || s.Length != 0 || s.Peak != 27 {
+		t.Errorf("stats after clearing are %+v", s)
+	}
+
+	r := NewRing(3)
+	r.AddAll(1, 2, 3, 4, 5)
+	if s := r.Stats(); s.Added != 5 || s.Removed != 2 || s.Length != 3 {
+		t.Errorf("ring stats are %+v", s)
+	}
+	if s := FromSlice([]interface{}{1, 2}).Stats(); s.Added != 2 || s.Peak != 2 {
+		t.Errorf("stats of a queue from a slice are %+v", s)
+	}
+}
+
+func TestQueueStatsSplitAndDecode(t *testing.T) {
+	q := New()
+	q.Fill(10, 0)
+	rest := q.Split(5)
+	if s := rest.Stats(); s != (QueueStats{Added: 5, Length: 5, Peak: 5}) {
+		t.Errorf("split queue has stats %+v", s)
+	}
+	if s := q.Stats(); s.Added != 10 || s.Removed != 5 || s.Length != 5 {
+		t.Errorf("queue split from has stats %+v", s)
+	}
+
+	var decoded Queue
+	if err := decoded.UnmarshalJSON([]byte(`[1, 2]`)); err != nil {
+		t.Fatal(err)
+	}
+	if s := decoded.Stats(); s.Added != 2 || s.Removed != 0 || s.Length != 2 {
+		t.Errorf("decoded queue has stats %+v", s)
+	}
+	if err := decoded.UnmarshalJSON([]byte(`[3]`)); err != nil {
+		t.Fatal(err)
+	}
+	if s := decoded.Stats(); s.Added != 3 || s.Removed != 2 || s.Length != 1 {
+		t.Errorf("redecoded queue has stats %+v", s)
+	}
+}
@@ -0,0 +1,46 @@
+package queue
+
+import "sync"
+
+// SyncQueue wraps a Queue with a mutex so that it can be shared between goroutines. It is
+// kept separate from Queue so that single-goroutine users don't pay for the locking.
+type SyncQueue struct {
+	mu sync.Mutex
+	q  *Queue
+}
+
+// NewSync constructs and returns a new SyncQueue.
+func NewSync() *SyncQueue {
+	return &SyncQueue{q: New()}
+}
+
+// Length returns the number of elements currently stored in the queue.
+func (s *SyncQueue) Length() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.q.Length()
+}
+
+// Add puts an element on the end of the queue.
+func (s *SyncQueue) Add(elem interface{}) {
+	s.mu.Lock()
+	s.q.Add(elem)
+	s.mu.Unlock()
+}
+
+// Peek returns the element at the head of the queue and true, or nil and false if the
+// queue is empty.
+func (s *SyncQueue) Peek() (interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.q.TryPeek()
+}
+
+// Pop removes and returns the element at the head of the queue, or returns nil and false if
+// the queue is empty. Checking for and removing the element happen under a single lock, so
+// two goroutines can never pop the same element.
+func (s *SyncQueue) Pop() (interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.q.PopOK()
+}
@@ -0,0 +1,68 @@
+package queue
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestSyncQueue(t *testing.T) {
+	s := NewSync()
+
+	if _, ok := s.Peek(); ok {
+		t.Error("peek on empty queue succeeded")
+	}
+	if _, ok := s.Pop(); ok {
+		t.Error("pop on empty queue succeeded")
+	}
+
+	s.Add(1)
+	s.Add(2)
+	if v, ok := s.Peek(); !ok || v.(int) != 1 {
+		t.Errorf("peek returned %v, %v", v, ok)
+	}
+	if v, ok := s.Pop(); !ok || v.(int) != 1 {
+		t.Errorf("pop returned %v, %v", v, ok)
+	}
+	if s.Length() != 1 {
+		t.Error("queue has length", s.Length())
+	}
+}
+
+func TestSyncQueueConcurrent(t *testing.T) {
+	const producers, perProducer = 8, 1000
+	s := NewSync()
+
+	var wg sync.WaitGroup
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perProducer; i++ {
+				s.Add(1)
+			}
+		}()
+	}
+
+	var mu sync.Mutex
+	popped := 0
+	for c := 0; c < producers; c++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n := 0
+			for i := 0; i < perProducer; i++ {
+				if _, ok := s.Pop(); ok {
+					n++
+				}
+			}
+			mu.Lock()
+			popped += n
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if popped+s.Length() != producers*perProducer {
+		t.Errorf("popped %d and left %d of %d elements", popped, s.Length(), producers*perProducer)
+	}
+}
@@ -0,0 +1,76 @@
+package queue
+
+import "time"
+
+// TimedQueue wraps a Queue to record when each element was added, so that callers can tell
+// how long elements have been waiting and drop stale ones.
+type TimedQueue struct {
+	q   *Queue
+	now func() time.Time
+}
+
+type timedEntry struct {
+	elem  interface{}
+	added time.Time
+}
+
+// NewTimed constructs and returns a new TimedQueue.
+func NewTimed() *TimedQueue {
+	return &TimedQueue{q: New(), now: time.Now}
+}
+
+// Length returns the number of elements currently stored in the queue.
+func (t *TimedQueue) Length() int {
+	return t.q.Length()
+}
+
+// Add puts an element on the end of the queue, stamped with the current time.
+func (t *TimedQueue) Add(elem interface{}) {
+	t.q.Add(timedEntry{elem, t.now()})
+}
+
+// Peek returns the element at the head of the queue and true, or nil and false if the queue
+// is empty.
+func (t *TimedQueue) Peek() (interface{}, bool) {
+	e, ok := t.q.TryPeek()
+	if !ok {
+		return nil, false
+	}
+	return e.(timedEntry).elem, true
+}
+
+// Pop removes and returns the element at the head of the queue and true, or returns nil and
+// false if the queue is empty.
+func (t *TimedQueue) Pop() (interface{}, bool) {
+	e, ok := t.q.PopOK()
+	if !ok {
+		return nil, false
+	}
+	return e.(timedEntry).elem, true
+}
+
+// HeadAge returns how long the element at the head of the queue has been waiting, or 0 if
+// the queue is empty.
+func (t *TimedQueue) HeadAge() time.Duration {
+	e, ok := t.q.TryPeek()
+	if !ok {
+		return 0
+	}
+	return t.now().Sub(e.(timedEntry).added)
+}
+
+// RemoveOlderThan removes the elements from the front of the queue that have been waiting
+// for longer than d, and returns how many were removed.
+func (t *TimedQueue) RemoveOlderThan(d time.Duration) int {
+	cutoff := t.now().Add(-d)
+	n := 0
+	t.q.ForEach(func(_ int, e interface{}) bool {
+		if !e.(timedEntry).added.Before(cutoff) {
+			return false
+		}
+		n++
+		return true
+	})
+	t.q.RemoveN(n)
+	return n
+}
@@ -0,0 +1,43 @@
+package queue
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimedQueue(t *testing.T) {
+	clock := time.Unix(1000, 0)
+	q := NewTimed()
+	q.now = func() time.Time { return clock }
+
+	if q.HeadAge() != 0 {
+		t.Error("empty queue has head age", q.HeadAge())
+	}
+	if _, ok := q.Peek(); ok {
+		t.Error("peek on empty queue succeeded")
+	}
+
+	for i := 0; i < 10; i++ {
+		q.Add(i)
+		clock = clock.Add(time.Second)
+	}
+	if q.HeadAge() != 10*time.Second {
+		t.Error("head age is", q.HeadAge())
+	}
+	if v, ok := q.Peek(); !ok || v.(int) != 0 {
+		t.Errorf("peek returned %v, %v", v, ok)
+	}
+
+	if n := q.RemoveOlderThan(7 * time.Second); n != 3 {
+		t.Error("removed", n, "stale elements")
+	}
+	if v, ok := q.Pop(); !ok || v.(int) != 3 || q.Length() != 6 {
+		t.Errorf("pop returned %v, %v", v, ok)
+	}
+	if n := q.RemoveOlderThan(time.Hour); n != 0 {
+		t.Error("removed", n, "fresh elements")
+	}
+	if n := q.RemoveOlderThan(0); n != 6 || q.Length() != 0 {
+		t.Error("removed", n, "elements with a zero age limit")
+	}
+}
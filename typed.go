@@ -0,0 +1,65 @@
+package queue
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// NewTyped constructs and returns a new Queue that only accepts elements of the same dynamic
+// type as sample. Adding or setting an element of any other type panics, which catches
+// mistakes such as queuing a *Job where a Job was expected at the add site, rather than at
+// a type assertion far away. sample itself is not added, and must not be nil. Queues from
+// New skip the check entirely.
+func NewTyped(sample interface{}) *Queue {
+	if sample == nil {
+		panic("typed queue sample must not be nil")
+	}
+	q := New()
+	q.typ = reflect.TypeOf(sample)
+	return q
+}
+
+// SetValidator registers a function that vets every element before it is added to or set in
+// the queue. An element for which validate returns an error is not stored: AddChecked
+// returns the error, and every other method panics with it. A nil validate, the default,
+// removes the check.
+func (q *Queue) SetValidator(validate func(elem interface{}) error) {
+	q.validate = validate
+}
+
+// AddChecked puts an element on the end of the queue like Add, unless the queue doesn't
+// accept it, in which case the queue is left unchanged and an error is returned instead of
+// panicking: the validator's error if the validator set with SetValidator rejects it, or an
+// error naming both types if the queue is typed and elem is of another type.
+func (q *Queue) AddChecked(elem interface{}) error {
+	if err := q.elemError(elem); err != nil {
+		return err
+	}
+	q.push(elem)
+	return nil
+}
+
+// elemError returns an error if the queue is typed and elem is not of its type, or the
+// validator's error if it rejects elem.
+func (q *Queue) elemError(elem interface{}) error {
+	if q.typ != nil && reflect.TypeOf(elem) != q.typ {
+		return fmt.Errorf("queue: cannot add %T to a queue of %v", elem, q.typ)
+	}
+	if q.validate != nil {
+		return q.validate(elem)
+	}
+	return nil
+}
+
+// checkElem panics if the queue is typed and elem is not of its type, or if the validator
+// rejects elem.
+func (q *Queue) checkElem(elem interface{}) {
+	if q.typ != nil && reflect.TypeOf(elem) != q.typ {
+		panic(fmt.Sprintf("cannot add %T to a queue of %v", elem, q.typ))
+	}
+	if q.validate != nil {
+		if err := q.validate(elem); err != nil {
+			panic(err)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package queue
+
+import (
+	"errors"
+	"testing"
+)
+
+type typedJob struct{ id int }
+
+func TestNewTyped(t *testing.T) {
+	q := NewTyped(typedJob{})
+
+	q.Add(typedJob{1})
+	q.AddFront(typedJob{0})
+	q.AddAll(typedJob{2}, typedJob{3})
+	q.Insert(2, typedJob{9})
+	q.Set(2, typedJob{10})
+	if q.Length() != 5 || q.Get(2).(typedJob).id != 10 {
+		t.Errorf("typed queue holds %v", q)
+	}
+
+	bad := []func(){
+		func() { q.Add(&typedJob{}) },
+		func() { q.Add(nil) },
+		func() { q.AddFront(1) },
+		func() { q.AddAll(typedJob{}, "x") },
+		func() { q.Insert(0, 1) },
+		func() { q.Set(0, 1) },
+		func() { q.Fill(3, 1) },
+		func() { q.Merge(FromSlice([]interface{}{typedJob{}, 1})) },
+		func() { q.Transform(func(interface{}) interface{} { return 1 }) },
+	}
+	for i, fn := range bad {
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("case %d should panic on the wrong type", i)
+				}
+			}()
+			fn()
+		}()
+		if q.Length() != 5 {
+			t.Fatalf("case %d changed the length to %d", i, q.Length())
+		}
+	}
+
+	New().Add(&typedJob{})
+	New().Add(1)
+}
+
+func TestNewTypedNilPanics(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("should panic on a nil sample")
+		}
+	}()
+	NewTyped(nil)
+}
+
+func TestQueueSetValidator(t *testing.T) {
+	errNegative := errors.New("negative")
+	q := New()
+	q.SetValidator(func(elem interface{}) error {
+		if elem.(int) < 0 {
+			return errNegative
+		}
+		return nil
+	})
+
+	if err := q.AddChecked(1); err != nil {
+		t.Error("valid element rejected with", err)
+	}
+	if err := q.AddChecked(-1); err != errNegative || q.Length() != 1 {
+		t.Errorf("invalid element returned %v, length %d", err, q.Length())
+	}
+	q.Add(2)
+	q.AddAll(3, 4)
+
+	bad := []func(){
+		func() { q.Add(-1) },
+		func() { q.AddFront(-1) },
+		func() { q.AddAll(5, -1) },
+		func() { q.Insert(0, -1) },
+		func() { q.Set(0, -1) },
+		func() { q.Merge(FromSlice([]interface{}{5, -1})) },
+		func() { q.Transform(func(interface{}) interface{} { return -1 }) },
+	}
+	for i, fn := range bad {
+		func() {
+			defer func() {
+				if r := recover(); r != errNegative {
+					t.Errorf("case %d panicked with %v", i, r)
+				}
+			}()
+			fn()
+		}()
+		if q.Length() != 4 {
+			t.Fatalf("case %d changed the length to %d", i, q.Length())
+		}
+	}
+
+	q.SetValidator(nil)
+	q.Add(-1)
+	if err := q.AddChecked(-2); err != nil || q.Length() != 6 {
+		t.Errorf("queue without a validator returned %v, length %d", err, q.Length())
+	}
+}
+
+func TestQueueValidatorCalls(t *testing.T) {
+	q := NewTyped(0)
+	if err := q.AddChecked("x"); err == nil || q.Length() != 0 {
+		t.Errorf("adding a string to a typed queue returned %v, length %d", err, q.Length())
+	}
+
+	calls := 0
+	r := NewRing(4)
+	r.SetValidator(func(interface{}) error { calls++; return nil })
+	r.AddAll(1, 2)
+	r.AddFrontAll(3, 4)
+	r.Merge(FromSlice([]interface{}{5, 6}))
+	FromSlice([]interface{}{7, 8}).MoveTo(r, 2)
+	if calls != 8 || r.String() != "Queue[5 6 7 8]" {
+		t.Errorf("validator ran %d times for 8 elements, ring holds %v", calls, r)
+	}
+}
@@ -0,0 +1,66 @@
+package queue
+
+// WeightedQueue wraps a Queue to bound the total weight of its elements rather than their
+// number, for example the total size of queued byte slices. The weight of each element is
+// given by a weigh function, which must return the same non-negative value every time it is
+// called on the same element.
+type WeightedQueue struct {
+	q         *Queue
+	weigh     func(elem interface{}) int
+	weight    int
+	maxWeight int
+}
+
+// NewWeighted constructs and returns a new WeightedQueue whose elements, as measured by
+// weigh, never add up to more than maxWeight.
+func NewWeighted(maxWeight int, weigh func(elem interface{}) int) *WeightedQueue {
+	return &WeightedQueue{q: New(), weigh: weigh, maxWeight: maxWeight}
+}
+
+// Length returns the number of elements currently stored in the queue.
+func (w *WeightedQueue) Length() int {
+	return w.q.Length()
+}
+
+// Weight returns the total weight of the elements currently stored in the queue.
+func (w *WeightedQueue) Weight() int {
+	return w.weight
+}
+
+// Add puts an element on the end of the queue and returns true, or returns false and leaves
+// the queue untouched if that would take its weight over the maximum. It panics if weigh
+// returns a negative weight.
+func (w *WeightedQueue) Add(elem interface{}) bool {
+	n := w.weigh(elem)
+	if n < 0 {
+		panic("element weight must not be negative")
+	}
+	if n > w.maxWeight-w.weight {
+		return false
+	}
+	w.q.Add(elem)
+	w.weight += n
+	return true
+}
+
+// Peek returns the element at the head of the queue and true, or nil and false if the queue
+// is empty.
+func (w *WeightedQueue) Peek() (interface{}, bool) {
+	return w.q.TryPeek()
+}
+
+// Pop removes and returns the element at the head of the queue, releasing its weight, or
+// returns nil and false if the queue is empty.
+func (w *WeightedQueue) Pop() (interface{}, bool) {
+	elem, ok := w.q.PopOK()
+	if ok {
+		w.weight -= w.weigh(elem)
+	}
+	return elem, ok
+}
+
+// Remove removes the element at the head of the queue, releasing its weight. It does
+// nothing if the queue is empty.
+func (w *WeightedQueue) Remove() {
+	w.Pop()
+}
@@ -0,0 +1,41 @@
+package queue
+
+import "testing"
+
+func TestWeightedQueue(t *testing.T) {
+	w := NewWeighted(10, func(elem interface{}) int { return len(elem.([]byte)) })
+
+	if _, ok := w.Pop(); ok {
+		t.Error("pop on empty queue succeeded")
+	}
+	w.Remove()
+
+	for _, n := range []int{4, 3, 3} {
+		if !w.Add(make([]byte, n)) {
+			t.Fatal("failed to add", n, "bytes at weight", w.Weight())
+		}
+	}
+	if w.Add(make([]byte, 1)) {
+		t.Error("add over the maximum weight succeeded")
+	}
+	if !w.Add([]byte{}) {
+		t.Error("failed to add an element with no weight")
+	}
+	if w.Length() != 4 || w.Weight() != 10 {
+		t.Errorf("queue has length %d and weight %d", w.Length(), w.Weight())
+	}
+
+	if v, ok := w.Peek(); !ok || len(v.([]byte)) != 4 {
+		t.Errorf("peek returned %v, %v", v, ok)
+	}
+	w.Remove()
+	if w.Weight() != 6 {
+		t.Error("remove left weight", w.Weight())
+	}
+	if !w.Add(make([]byte, 4)) {
+		t.Error("failed to add after making room")
+	}
+	if v, ok := w.Pop(); !ok || len(v.([]byte)) != 3 || w.Weight() != 7 {
+		t.Errorf("pop returned %v, %v, leaving weight %d", v, ok, w.Weight())
+	}
+}
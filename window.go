@@ -0,0 +1,42 @@
+package queue
+
+// MovingWindow keeps the last few values of a stream and their running average. It is built
+// on a ring from NewRing, so adding to a full window evicts its oldest value.
+type MovingWindow struct {
+	ring *Queue
+	sum  float64
+}
+
+// NewMovingWindow constructs and returns a new MovingWindow over the last size values. It
+// panics if size is less than one.
+func NewMovingWindow(size int) *MovingWindow {
+	w := &MovingWindow{ring: NewRing(size)}
+	w.ring.SetOnEvict(func(evicted interface{}) {
+		w.sum -= evicted.(float64)
+	})
+	return w
+}
+
+// Length returns the number of values currently in the window.
+func (w *MovingWindow) Length() int {
+	return w.ring.Length()
+}
+
+// Add puts x in the window, evicting the oldest value if the window is full.
+func (w *MovingWindow) Add(x float64) {
+	w.ring.Add(x)
+	w.sum += x
+}
+
+// Sum returns the sum of the values in the window.
+func (w *MovingWindow) Sum() float64 {
+	return w.sum
+}
+
+// Average returns the mean of the values in the window, or 0 if it is empty.
+func (w *MovingWindow) Average() float64 {
+	if w.ring.Length() == 0 {
+		return 0
+	}
+	return w.sum / float64(w.ring.Length())
+}
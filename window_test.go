@@ -0,0 +1,22 @@
+package queue
+
+import "testing"
+
+func TestMovingWindow(t *testing.T) {
+	w := NewMovingWindow(4)
+
+	if w.Average() != 0 || w.Length() != 0 {
+		t.Error("empty window has average", w.Average())
+	}
+	w.Add(1)
+	w.Add(2)
+	if w.Average() != 1.5 {
+		t.Error("partial window has average", w.Average())
+	}
+	for _, x := range []float64{3, 4, 5, 6} {
+		w.Add(x)
+	}
+	if w.Length() != 4 || w.Sum() != 18 || w.Average() != 4.5 {
+		t.Errorf("full window has length %d, sum %v and average %v", w.Length(), w.Sum(), w.Average())
+	}
+}
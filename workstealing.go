@@ -0,0 +1,101 @@
+package queue
+
+import "sync/atomic"
+
+// WorkStealingDeque is a fixed-capacity Chase-Lev work-stealing deque. A single owner
+// goroutine pushes and pops elements at the bottom, while any number of other goroutines
+// steal elements from the top, without locks. Since a resize can't be done lock-free, the
+// deque never grows: PushBottom fails when it is full.
+//
+// Slots are not cleared when elements are popped or stolen, as a thief may still be reading
+// them, so the deque keeps up to its capacity's worth of removed elements alive until their
+// slots are reused.
+type WorkStealingDeque struct {
+	top    int64 // next element to steal, advanced by thieves and by the owner's last pop
+	bottom int64 // next slot to push, written only by the owner
+	buf    []atomic.Value
+	mask   int64
+}
+
+// wsSlot boxes elements so that every atomic.Value in the buffer holds the same type, and so
+// that nil elements can be stored.
+type wsSlot struct {
+	elem interface{}
+}
+
+// NewWorkStealingDeque constructs and returns a new WorkStealingDeque that holds at least
+// capacity elements. The capacity is rounded up to a power of two. It panics if capacity is
+// less than one.
+func NewWorkStealingDeque(capacity int) *WorkStealingDeque {
+	if capacity < 1 {
+		panic("work-stealing deque capacity must be at least 1")
+	}
+	n := roundCapacity(capacity, 1)
+	return &WorkStealingDeque{buf: make([]atomic.Value, n), mask: int64(n - 1)}
+}
+
+// Length returns the number of elements currently stored in the deque. When called while
+// other goroutines are using the deque, the result may be out of date by the time it is
+// returned.
+func (d *WorkStealingDeque) Length() int {
+	b := atomic.LoadInt64(&d.bottom)
+	t := atomic.LoadInt64(&d.top)
+	if b < t {
+		return 0
+	}
+	return int(b - t)
+}
+
+// PushBottom puts an element on the bottom of the deque and returns true, or returns false
+// if the deque is full. It must only be called by the owner.
+func (d *WorkStealingDeque) PushBottom(elem interface{}) bool {
+	b := atomic.LoadInt64(&d.bottom)
+	if b-atomic.LoadInt64(&d.top) >= int64(len(d.buf)) {
+		return false
+	}
+	d.buf[b&d.mask].Store(wsSlot{elem})
+	atomic.StoreInt64(&d.bottom, b+1)
+	return true
+}
+
+// PopBottom removes and returns the element at the bottom of the deque, the one most
+// recently pushed, and true, or returns nil and false if the deque is empty. It must only be
+// called by the owner.
+func (d *WorkStealingDeque) PopBottom() (interface{}, bool) {
+	b := atomic.LoadInt64(&d.bottom) - 1
+	atomic.StoreInt64(&d.bottom, b)
+	t := atomic.LoadInt64(&d.top)
+	if t > b {
+		atomic.StoreInt64(&d.bottom, b+1)
+		return nil, false
+	}
+
+	elem := d.buf[b&d.mask].Load().(wsSlot).elem
+	if t < b {
+		return elem, true
+	}
+	// this is the last element, so race the thieves for it
+	ok := atomic.CompareAndSwapInt64(&d.top, t, t+1)
+	atomic.StoreInt64(&d.bottom, b+1)
+	if !ok {
+		return nil, false
+	}
+	return elem, true
+}
+
+// Steal removes and returns the element at the top of the deque, the one least recently
+// pushed, and true. It returns nil and false if the deque is empty or if another goroutine
+// took the element first, in which case the caller may simply try again. It is safe to call
+// from any goroutine.
+func (d *WorkStealingDeque) Steal() (interface{}, bool) {
+	t := atomic.LoadInt64(&d.top)
+	b := atomic.LoadInt64(&d.bottom)
+	if t >= b {
+		return nil, false
+	}
+	elem := d.buf[t&d.mask].Load().(wsSlot).elem
+	if !atomic.CompareAndSwapInt64(&d.top, t, t+1) {
+		return nil, false
+	}
+	return elem, true
+}
@@ -0,0 +1,82 @@
+package queue
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWorkStealingDeque(t *testing.T) {
+	d := NewWorkStealingDeque(3)
+
+	if _, ok := d.PopBottom(); ok {
+		t.Error("pop on empty deque succeeded")
+	}
+	if _, ok := d.Steal(); ok {
+		t.Error("steal on empty deque succeeded")
+	}
+	for i := 0; i < 4; i++ {
+		if !d.PushBottom(i) {
+			t.Fatal("push failed at", i)
+		}
+	}
+	if d.PushBottom(4) {
+		t.Error("push on full deque succeeded")
+	}
+	if v, ok := d.Steal(); !ok || v.(int) != 0 {
+		t.Errorf("steal returned %v, %v", v, ok)
+	}
+	if v, ok := d.PopBottom(); !ok || v.(int) != 3 {
+		t.Errorf("pop returned %v, %v", v, ok)
+	}
+	if d.Length() != 2 {
+		t.Error("deque has length", d.Length())
+	}
+	if !d.PushBottom(nil) {
+		t.Error("push failed after making room")
+	}
+	if v, ok := d.PopBottom(); !ok || v != nil {
+		t.Errorf("pop returned %v, %v", v, ok)
+	}
+}
+
+func TestWorkStealingDequeConcurrent(t *testing.T) {
+	const n, thieves = 100000, 4
+	d := NewWorkStealingDeque(64)
+	seen := make([]int32, n)
+	var done int32
+
+	var wg sync.WaitGroup
+	for i := 0; i < thieves; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for atomic.LoadInt32(&done) == 0 || d.Length() > 0 {
+				if v, ok := d.Steal(); ok {
+					atomic.AddInt32(&seen[v.(int)], 1)
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		for !d.PushBottom(i) {
+			if v, ok := d.PopBottom(); ok {
+				atomic.AddInt32(&seen[v.(int)], 1)
+			}
+		}
+		if i%3 == 0 {
+			if v, ok := d.PopBottom(); ok {
+				atomic.AddInt32(&seen[v.(int)], 1)
+			}
+		}
+	}
+	atomic.StoreInt32(&done, 1)
+	wg.Wait()
+
+	for i, c := range seen {
+		if c != 1 {
+			t.Fatalf("element %d taken %d times", i, c)
+		}
+	}
+}